@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/khulnasoft-lab/misscan/pkg/rego"
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/spf13/cobra"
+)
+
+var depsFlags struct {
+	format            string
+	embeddedLibraries bool
+	embeddedPolicies  bool
+}
+
+func init() {
+	depsCmd.Flags().StringVarP(&depsFlags.format, "format", "f", "text", "output format (text, json)")
+	depsCmd.Flags().BoolVar(&depsFlags.embeddedLibraries, "embedded-libraries", true, "include the embedded rego libraries")
+	depsCmd.Flags().BoolVar(&depsFlags.embeddedPolicies, "embedded-policies", false, "include the embedded rego policies")
+	rootCmd.AddCommand(depsCmd)
+}
+
+var depsCmd = &cobra.Command{
+	Use:   "deps [path...]",
+	Short: "show the import graph of misscan policies and libraries",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceErrors = true
+		cmd.SilenceUsage = true
+
+		modules := make(map[string]*ast.Module)
+		if depsFlags.embeddedLibraries {
+			libs, err := rego.LoadEmbeddedLibraries()
+			if err != nil {
+				return fmt.Errorf("failed to load embedded rego libraries: %w", err)
+			}
+			for name, module := range libs {
+				modules[name] = module
+			}
+		}
+		if depsFlags.embeddedPolicies {
+			policies, err := rego.LoadEmbeddedPolicies()
+			if err != nil {
+				return fmt.Errorf("failed to load embedded rego policies: %w", err)
+			}
+			for name, module := range policies {
+				modules[name] = module
+			}
+		}
+		if len(args) > 0 {
+			srcFS, paths, err := hostPaths(args...)
+			if err != nil {
+				return err
+			}
+			loaded, err := rego.LoadPoliciesFromDirs(srcFS, paths...)
+			if err != nil {
+				return err
+			}
+			for name, module := range loaded {
+				modules[name] = module
+			}
+		}
+
+		report := rego.AnalyseDependencies(modules)
+
+		switch depsFlags.format {
+		case "json":
+			encoder := json.NewEncoder(cmd.OutOrStdout())
+			encoder.SetIndent("", "  ")
+			if err := encoder.Encode(report); err != nil {
+				return err
+			}
+		case "text":
+			writeDependencyReport(cmd.OutOrStdout(), report)
+		default:
+			return fmt.Errorf("unsupported format %q", depsFlags.format)
+		}
+
+		if report.Failed() {
+			return fmt.Errorf("dependency check failed")
+		}
+		return nil
+	},
+}
+
+func writeDependencyReport(w io.Writer, report *rego.DependencyReport) {
+	for _, pkg := range report.Packages {
+		kind := "check"
+		if pkg.Library {
+			kind = "library"
+		}
+		_, _ = fmt.Fprintf(w, "%s (%s)\n", pkg.Package, kind)
+		if len(pkg.Imports) > 0 {
+			_, _ = fmt.Fprintf(w, "  imports:     %s\n", strings.Join(pkg.Imports, ", "))
+		}
+		if len(pkg.ImportedBy) > 0 {
+			_, _ = fmt.Fprintf(w, "  imported by: %d package(s)\n", len(pkg.ImportedBy))
+		}
+	}
+	_, _ = fmt.Fprintln(w, "--------------------------------------------------------------------------------")
+	for _, namespace := range report.UnusedLibraries {
+		_, _ = fmt.Fprintf(w, "unused library: %s\n", namespace)
+	}
+	for _, missing := range report.MissingImports {
+		_, _ = fmt.Fprintf(w, "missing import: %s:%d: %s imports %s\n", missing.Filename, missing.Row, missing.Package, missing.Import)
+	}
+	for _, cycle := range report.Cycles {
+		_, _ = fmt.Fprintf(w, "import cycle: %s\n", strings.Join(cycle, " <-> "))
+	}
+	_, _ = fmt.Fprintf(w, "%d package(s), %d unused library(s), %d missing import(s), %d cycle(s)\n",
+		len(report.Packages), len(report.UnusedLibraries), len(report.MissingImports), len(report.Cycles))
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/khulnasoft-lab/misscan/pkg/rego"
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/spf13/cobra"
+)
+
+var lintFlags struct {
+	format            string
+	embeddedLibraries bool
+}
+
+func init() {
+	lintCmd.Flags().StringVarP(&lintFlags.format, "format", "f", "text", "output format (text, json)")
+	lintCmd.Flags().BoolVar(&lintFlags.embeddedLibraries, "embedded-libraries", true, "compile policies with the embedded rego libraries")
+	rootCmd.AddCommand(lintCmd)
+}
+
+var lintCmd = &cobra.Command{
+	Use:   "lint [path...]",
+	Short: "check the metadata of misscan policies",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceErrors = true
+		cmd.SilenceUsage = true
+
+		modules := make(map[string]*ast.Module)
+		if lintFlags.embeddedLibraries {
+			libs, err := rego.LoadEmbeddedLibraries()
+			if err != nil {
+				return fmt.Errorf("failed to load embedded rego libraries: %w", err)
+			}
+			for name, module := range libs {
+				modules[name] = module
+			}
+		}
+		srcFS, paths, err := hostPaths(args...)
+		if err != nil {
+			return err
+		}
+		loaded, err := rego.LoadPoliciesFromDirs(srcFS, paths...)
+		if err != nil {
+			return err
+		}
+		for name, module := range loaded {
+			modules[name] = module
+		}
+
+		report, err := rego.LintPolicies(cmd.Context(), modules)
+		if err != nil {
+			return err
+		}
+
+		switch lintFlags.format {
+		case "json":
+			encoder := json.NewEncoder(cmd.OutOrStdout())
+			encoder.SetIndent("", "  ")
+			if err := encoder.Encode(report); err != nil {
+				return err
+			}
+		case "text":
+			for _, finding := range report.Findings {
+				_, _ = fmt.Fprintln(cmd.OutOrStdout(), finding)
+			}
+		default:
+			return fmt.Errorf("unsupported format %q", lintFlags.format)
+		}
+
+		if report.Failed() {
+			return fmt.Errorf("lint failed")
+		}
+		return nil
+	},
+}
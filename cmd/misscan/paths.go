@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// hostPaths opens a filesystem containing the given paths and returns them relative to it. Paths inside the current
+// directory are resolved against it, so they are reported as given, otherwise the filesystem is opened at the root.
+func hostPaths(paths ...string) (fs.FS, []string, error) {
+	local := true
+	for _, path := range paths {
+		if !filepath.IsLocal(path) {
+			local = false
+			break
+		}
+	}
+	if local {
+		resolved := make([]string, 0, len(paths))
+		for _, path := range paths {
+			resolved = append(resolved, filepath.ToSlash(filepath.Clean(path)))
+		}
+		return os.DirFS("."), resolved, nil
+	}
+
+	var volume string
+	resolved := make([]string, 0, len(paths))
+	for i, path := range paths {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to resolve %q: %w", path, err)
+		}
+		vol := filepath.VolumeName(abs)
+		if i == 0 {
+			volume = vol
+		} else if vol != volume {
+			return nil, nil, fmt.Errorf("paths must be on the same volume: %q is not on %q", path, volume)
+		}
+		rel := strings.TrimPrefix(filepath.ToSlash(abs[len(vol):]), "/")
+		if rel == "" {
+			rel = "."
+		}
+		resolved = append(resolved, rel)
+	}
+	return os.DirFS(volume + string(filepath.Separator)), resolved, nil
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_hostPaths(t *testing.T) {
+	_, paths, err := hostPaths("policies", "./lib/../data")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"policies", "data"}, paths)
+
+	dir := t.TempDir()
+	srcFS, paths, err := hostPaths("policies", dir)
+	require.NoError(t, err)
+	abs, err := filepath.Abs("policies")
+	require.NoError(t, err)
+	assert.Equal(t, strings.TrimPrefix(filepath.ToSlash(abs[len(filepath.VolumeName(abs)):]), "/"), paths[0], "all paths are resolved against the root")
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.rego"), []byte("package a"), 0o600))
+	_, err = srcFS.Open(paths[1] + "/a.rego")
+	require.NoError(t, err)
+}
+
+func Test_TestCommand_AbsolutePath(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "foo_test.rego"), []byte(`package user.foo
+
+test_ok {
+	true
+}
+`), 0o600))
+
+	var out bytes.Buffer
+	rootCmd.SetOut(&out)
+	rootCmd.SetArgs([]string{"test", dir})
+	t.Cleanup(func() {
+		rootCmd.SetOut(nil)
+		rootCmd.SetArgs(nil)
+	})
+	require.NoError(t, rootCmd.Execute())
+	assert.Contains(t, out.String(), "PASS  data.user.foo.test_ok")
+	assert.Contains(t, out.String(), "PASS: 1/1")
+}
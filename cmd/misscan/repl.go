@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/khulnasoft-lab/misscan/pkg/providers/dockerfile"
+	"github.com/khulnasoft-lab/misscan/pkg/rego"
+	"github.com/khulnasoft-lab/misscan/pkg/scanners/options"
+	"github.com/khulnasoft-lab/misscan/pkg/state"
+	"github.com/khulnasoft-lab/misscan/pkg/types"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var replFlags struct {
+	dataDirs          []string
+	source            string
+	input             string
+	inputType         string
+	embeddedLibraries bool
+	embeddedPolicies  bool
+}
+
+func init() {
+	replCmd.Flags().StringSliceVarP(&replFlags.dataDirs, "data", "d", nil, "directories containing data documents")
+	replCmd.Flags().StringVarP(&replFlags.source, "source", "s", "", "source type whose input schema and policies are loaded, e.g. dockerfile")
+	replCmd.Flags().StringVarP(&replFlags.input, "input", "i", "", "file to use as input")
+	replCmd.Flags().StringVarP(&replFlags.inputType, "input-type", "t", "raw", "how the input file is converted (raw, state, dockerfile-json)")
+	replCmd.Flags().BoolVar(&replFlags.embeddedLibraries, "embedded-libraries", true, "load the embedded rego libraries")
+	replCmd.Flags().BoolVar(&replFlags.embeddedPolicies, "embedded-policies", false, "load the embedded rego policies")
+	rootCmd.AddCommand(replCmd)
+}
+
+var replCmd = &cobra.Command{
+	Use:   "repl [path...]",
+	Short: "evaluate queries interactively against misscan policies and an input",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceErrors = true
+		cmd.SilenceUsage = true
+
+		srcFS, paths, err := hostPaths(append(append([]string{}, args...), replFlags.dataDirs...)...)
+		if err != nil {
+			return err
+		}
+
+		scanner := rego.NewScanner(
+			types.Source(replFlags.source),
+			options.ScannerWithDataDirs(paths[len(args):]...),
+		)
+		if err := scanner.LoadPolicies(replFlags.embeddedLibraries, replFlags.embeddedPolicies, srcFS, paths[:len(args)], nil); err != nil {
+			return err
+		}
+
+		session := &replSession{
+			scanner:   scanner,
+			inputType: replFlags.inputType,
+			out:       cmd.OutOrStdout(),
+		}
+		if replFlags.input != "" {
+			if err := session.load(replFlags.input); err != nil {
+				return err
+			}
+		}
+		return session.run(cmd.Context(), cmd.InOrStdin())
+	},
+}
+
+const replHelp = `Enter a query to evaluate it, e.g. input.Stages[0].Commands[_].Cmd, or one of:
+  :input               show the input
+  :load <file>         use a file as the input
+  :namespaces          list the namespaces of the loaded policies
+  :metadata <package>  show the metadata of a loaded policy
+  :help                show this message
+  :quit                exit`
+
+type replSession struct {
+	scanner   *rego.Scanner
+	inputType string
+	input     interface{}
+	out       io.Writer
+}
+
+func (r *replSession) run(ctx context.Context, in io.Reader) error {
+	lines := bufio.NewScanner(in)
+	lines.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	_, _ = fmt.Fprintln(r.out, "misscan repl - :help for commands")
+	for {
+		_, _ = fmt.Fprint(r.out, "> ")
+		if !lines.Scan() {
+			_, _ = fmt.Fprintln(r.out)
+			return lines.Err()
+		}
+		line := strings.TrimSpace(lines.Text())
+		if line == "" {
+			continue
+		}
+		if line == ":quit" || line == ":q" {
+			return nil
+		}
+		if err := r.execute(ctx, line); err != nil {
+			_, _ = fmt.Fprintf(r.out, "error: %s\n", err)
+		}
+	}
+}
+
+func (r *replSession) execute(ctx context.Context, line string) error {
+	command, argument, _ := strings.Cut(line, " ")
+	argument = strings.TrimSpace(argument)
+	switch command {
+	case ":help":
+		_, _ = fmt.Fprintln(r.out, replHelp)
+		return nil
+	case ":input":
+		return r.print(r.input)
+	case ":load":
+		if argument == "" {
+			return fmt.Errorf("usage: :load <file>")
+		}
+		return r.load(argument)
+	case ":namespaces":
+		policies, err := r.scanner.LoadedPolicies(ctx)
+		if err != nil {
+			return err
+		}
+		for _, policy := range policies {
+			_, _ = fmt.Fprintf(r.out, "%s (%s)\n", policy.Namespace, policy.Filename)
+		}
+		return nil
+	case ":metadata":
+		policies, err := r.scanner.LoadedPolicies(ctx)
+		if err != nil {
+			return err
+		}
+		namespace := strings.TrimPrefix(argument, "data.")
+		for _, policy := range policies {
+			if policy.Namespace == namespace {
+				return r.print(policy.Metadata)
+			}
+		}
+		return fmt.Errorf("no policy is loaded for package %q", argument)
+	}
+
+	if strings.HasPrefix(command, ":") {
+		return fmt.Errorf("unknown command %s, see :help", command)
+	}
+
+	set, err := r.scanner.Query(ctx, line, r.input)
+	if err != nil {
+		return err
+	}
+	if len(set) == 0 {
+		_, _ = fmt.Fprintln(r.out, "undefined")
+		return nil
+	}
+	for _, result := range set {
+		if len(result.Bindings) > 0 {
+			if err := r.print(result.Bindings); err != nil {
+				return err
+			}
+			continue
+		}
+		for _, expression := range result.Expressions {
+			if err := r.print(expression.Value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (r *replSession) print(value interface{}) error {
+	encoded, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintln(r.out, string(encoded))
+	return nil
+}
+
+// load reads the input from a file, converting it the same way as the scanners do for the input type
+func (r *replSession) load(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	input, err := convertInput(data, r.inputType)
+	if err != nil {
+		return fmt.Errorf("failed to convert %s: %w", path, err)
+	}
+	r.input = input
+	return nil
+}
+
+// convertInput converts the contents of an input file to the value the policies are evaluated against:
+//   - raw uses the JSON or YAML document as it is
+//   - state converts a JSON encoded state.State, as the cloud scanners do
+//   - dockerfile-json converts a JSON encoded dockerfile.Dockerfile, as the dockerfile scanner does once it has parsed a
+//     Dockerfile. Dockerfiles themselves cannot be loaded, as parsing them is left to the scanners.
+func convertInput(data []byte, inputType string) (interface{}, error) {
+	switch inputType {
+	case "raw":
+		var input interface{}
+		if err := yaml.Unmarshal(data, &input); err != nil {
+			return nil, err
+		}
+		return input, nil
+	case "state":
+		var s state.State
+		if err := json.Unmarshal(data, &s); err != nil {
+			return nil, err
+		}
+		return s.ToRego(), nil
+	case "dockerfile-json":
+		var d dockerfile.Dockerfile
+		if err := json.Unmarshal(data, &d); err != nil {
+			return nil, err
+		}
+		return d.ToRego(), nil
+	default:
+		return nil, fmt.Errorf("unsupported input type %q", inputType)
+	}
+}
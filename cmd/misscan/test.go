@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/khulnasoft-lab/misscan/pkg/rego"
+	"github.com/khulnasoft-lab/misscan/pkg/types"
+	"github.com/spf13/cobra"
+)
+
+var testFlags struct {
+	dataDirs          []string
+	source            string
+	run               string
+	coverage          bool
+	annotate          bool
+	format            string
+	embeddedLibraries bool
+}
+
+func init() {
+	testCmd.Flags().StringSliceVarP(&testFlags.dataDirs, "data", "d", nil, "directories containing data documents")
+	testCmd.Flags().StringVarP(&testFlags.source, "source", "s", "", "source type whose input schema the policies are compiled with, e.g. dockerfile")
+	testCmd.Flags().StringVarP(&testFlags.run, "run", "r", "", "only run tests matching this regular expression")
+	testCmd.Flags().BoolVarP(&testFlags.coverage, "coverage", "c", false, "report policy coverage")
+	testCmd.Flags().BoolVar(&testFlags.annotate, "annotate", false, "show policy source annotated with covered (+) and uncovered (-) lines")
+	testCmd.Flags().StringVarP(&testFlags.format, "format", "f", "text", "output format (text, json)")
+	testCmd.Flags().BoolVar(&testFlags.embeddedLibraries, "embedded-libraries", true, "load the embedded rego libraries")
+	rootCmd.AddCommand(testCmd)
+}
+
+var testCmd = &cobra.Command{
+	Use:   "test [path...]",
+	Short: "run the tests in _test.rego files against misscan policies",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceErrors = true
+		cmd.SilenceUsage = true
+
+		srcFS, paths, err := hostPaths(append(append([]string{}, args...), testFlags.dataDirs...)...)
+		if err != nil {
+			return err
+		}
+
+		runner := rego.NewTestRunner(types.Source(testFlags.source))
+		runner.SetDataDirs(paths[len(args):]...)
+		runner.SetUseEmbeddedLibraries(testFlags.embeddedLibraries)
+		runner.SetFilter(testFlags.run)
+		runner.SetCoverageEnabled(testFlags.coverage)
+
+		report, err := runner.Run(cmd.Context(), srcFS, paths[:len(args)]...)
+		if err != nil {
+			return err
+		}
+
+		switch testFlags.format {
+		case "json":
+			encoder := json.NewEncoder(cmd.OutOrStdout())
+			encoder.SetIndent("", "  ")
+			if err := encoder.Encode(report); err != nil {
+				return err
+			}
+		case "text":
+			writeTestReport(cmd.OutOrStdout(), report)
+		default:
+			return fmt.Errorf("unsupported format %q", testFlags.format)
+		}
+
+		if report.Failed() {
+			return fmt.Errorf("tests failed")
+		}
+		return nil
+	},
+}
+
+func writeTestReport(w io.Writer, report *rego.TestReport) {
+	counts := make(map[rego.TestStatus]int)
+	for _, result := range report.Results {
+		counts[result.Status]++
+		_, _ = fmt.Fprintf(w, "%-5s %s.%s (%s:%d) %s\n", result.Status, result.Package, result.Name, result.Filename, result.Line, result.Duration)
+		if result.Error != "" {
+			_, _ = fmt.Fprintf(w, "      %s\n", result.Error)
+		}
+		if result.Output != "" && result.Status != rego.TestStatusPass {
+			_, _ = fmt.Fprintf(w, "      %s\n", result.Output)
+		}
+	}
+	_, _ = fmt.Fprintln(w, "--------------------------------------------------------------------------------")
+	for _, status := range []rego.TestStatus{rego.TestStatusPass, rego.TestStatusFail, rego.TestStatusError, rego.TestStatusSkip} {
+		if counts[status] > 0 {
+			_, _ = fmt.Fprintf(w, "%s: %d/%d\n", status, counts[status], len(report.Results))
+		}
+	}
+
+	if report.Coverage == nil {
+		return
+	}
+	_, _ = fmt.Fprintln(w, "--------------------------------------------------------------------------------")
+	if testFlags.annotate {
+		_ = report.Coverage.WriteText(w)
+		return
+	}
+	for _, module := range report.Coverage.Modules {
+		_, _ = fmt.Fprintf(w, "%6.2f%% %s\n", module.Coverage, module.Filename)
+	}
+	_, _ = fmt.Fprintf(w, "%6.2f%% total\n", report.Coverage.Coverage)
+}
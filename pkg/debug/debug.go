@@ -7,6 +7,7 @@ import (
 	"path/filepath"
 	"runtime"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -15,12 +16,14 @@ const timeFormat = "04:05.000000000"
 type Logger struct {
 	writer io.Writer
 	prefix string
+	mu     *sync.Mutex // shared by extended loggers so writes to the same writer do not interleave
 }
 
 func New(w io.Writer, parts ...string) Logger {
 	return Logger{
 		writer: w,
 		prefix: strings.Join(parts, "."),
+		mu:     &sync.Mutex{},
 	}
 }
 
@@ -28,6 +31,7 @@ func (l *Logger) Extend(parts ...string) Logger {
 	return Logger{
 		writer: l.writer,
 		prefix: strings.Join(append([]string{l.prefix}, parts...), "."),
+		mu:     l.mu,
 	}
 }
 
@@ -37,6 +41,10 @@ func (l *Logger) Log(format string, args ...interface{}) {
 	}
 	message := fmt.Sprintf(format, args...)
 	line := fmt.Sprintf("%s %-32s %s\n", time.Now().Format(timeFormat), l.prefix, message)
+	if l.mu != nil {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+	}
 	_, _ = l.writer.Write([]byte(line))
 }
 
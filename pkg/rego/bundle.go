@@ -0,0 +1,179 @@
+package rego
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/open-policy-agent/opa/bundle"
+)
+
+// bundleKeyID is the key id used to verify bundle signatures, matching the default used by `opa build --signing-key`
+const bundleKeyID = "default"
+
+// loadPolicyBundles reads the configured OPA bundles from srcFS, returning the modules they contain (keyed by bundle
+// path and module path) and the merged data documents from each bundle
+func (s *Scanner) loadPolicyBundles(srcFS fs.FS) (map[string]*ast.Module, map[string]interface{}, error) {
+
+	verification, err := s.bundleVerificationConfig()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	modules := make(map[string]*ast.Module)
+	data := make(map[string]interface{})
+	roots := make(map[string]string) // root -> bundle path
+
+	for _, bundlePath := range s.policyBundles {
+		b, err := s.readCachedBundle(srcFS, bundlePath, verification)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to load bundle %s: %w", bundlePath, err)
+		}
+
+		if b.Manifest.Roots != nil {
+			for _, root := range *b.Manifest.Roots {
+				for existing, owner := range roots {
+					if bundle.RootPathsOverlap(root, existing) {
+						return nil, nil, fmt.Errorf("bundle %s root %q overlaps with root %q of bundle %s", bundlePath, root, existing, owner)
+					}
+				}
+				roots[root] = bundlePath
+			}
+		}
+
+		for _, mf := range b.Modules {
+			modules[mf.Path] = mf.Parsed
+		}
+
+		if err := mergeDocuments(data, b.Data); err != nil {
+			return nil, nil, fmt.Errorf("failed to merge data from bundle %s: %w", bundlePath, err)
+		}
+
+		s.debug.Log("Loaded bundle %s (revision %q) with %d modules.", bundlePath, b.Manifest.Revision, len(b.Modules))
+	}
+
+	return modules, data, nil
+}
+
+// readCachedBundle reads a bundle, reusing the bundle read by a previous load if the file has not changed since
+func (s *Scanner) readCachedBundle(srcFS fs.FS, bundlePath string, verification *bundle.VerificationConfig) (*bundle.Bundle, error) {
+	info, err := fs.Stat(srcFS, sanitisePath(bundlePath))
+	if err != nil {
+		return nil, err
+	}
+	if b, ok := s.reload.modules.getBundle(bundlePath, info); ok {
+		return b, nil
+	}
+	b, err := readBundle(srcFS, bundlePath, verification)
+	if err != nil {
+		return nil, err
+	}
+	s.reload.modules.putBundle(bundlePath, info, b)
+	return b, nil
+}
+
+func readBundle(srcFS fs.FS, bundlePath string, verification *bundle.VerificationConfig) (*bundle.Bundle, error) {
+	f, err := srcFS.Open(sanitisePath(bundlePath))
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	reader := bundle.NewCustomReader(bundle.NewTarballLoader(f)).
+		WithBaseDir(bundlePath).
+		WithProcessAnnotations(true).
+		WithSkipBundleVerification(verification == nil).
+		WithBundleVerificationConfig(verification)
+
+	b, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+func (s *Scanner) bundleVerificationConfig() (*bundle.VerificationConfig, error) {
+	if s.bundleKey == "" {
+		return nil, nil
+	}
+	key, err := os.ReadFile(s.bundleKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bundle verification key: %w", err)
+	}
+	algorithm, err := keyAlgorithm(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bundle verification key %q: %w", s.bundleKey, err)
+	}
+	return bundle.NewVerificationConfig(
+		map[string]*bundle.KeyConfig{
+			bundleKeyID: {
+				Key:       string(key),
+				Algorithm: algorithm,
+			},
+		},
+		bundleKeyID,
+		"",
+		nil,
+	), nil
+}
+
+// keyAlgorithm determines the signing algorithm from a PEM encoded RSA or ECDSA public key
+func keyAlgorithm(key []byte) (string, error) {
+	block, _ := pem.Decode(key)
+	if block == nil {
+		return "", fmt.Errorf("key is not PEM encoded")
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse public key: %w", err)
+	}
+	switch k := pub.(type) {
+	case *ecdsa.PublicKey:
+		switch k.Curve {
+		case elliptic.P384():
+			return "ES384", nil
+		case elliptic.P521():
+			return "ES512", nil
+		default:
+			return "ES256", nil
+		}
+	case *rsa.PublicKey:
+		return "RS256", nil
+	default:
+		return "", fmt.Errorf("unsupported public key type %T", pub)
+	}
+}
+
+// mergeDocuments merges src into dst, failing if the same leaf is defined in both
+func mergeDocuments(dst, src map[string]interface{}) error {
+	for key, value := range src {
+		existing, ok := dst[key]
+		if !ok {
+			if obj, ok := value.(map[string]interface{}); ok {
+				// objects are copied, as merging other documents into dst must not modify src
+				copied := make(map[string]interface{}, len(obj))
+				if err := mergeDocuments(copied, obj); err != nil {
+					return err
+				}
+				value = copied
+			}
+			dst[key] = value
+			continue
+		}
+		existingObj, ok1 := existing.(map[string]interface{})
+		valueObj, ok2 := value.(map[string]interface{})
+		if !ok1 || !ok2 {
+			return fmt.Errorf("conflicting value for key %q", key)
+		}
+		if err := mergeDocuments(existingObj, valueObj); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+package rego
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/khulnasoft-lab/misscan/pkg/scanners/options"
+	"github.com/khulnasoft-lab/misscan/pkg/types"
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/open-policy-agent/opa/bundle"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func buildBundle(t *testing.T, roots []string, modules map[string]string, data map[string]interface{}, signingKey *rsa.PrivateKey) []byte {
+	if data == nil {
+		data = make(map[string]interface{})
+	}
+	b := bundle.Bundle{
+		Manifest: bundle.Manifest{
+			Revision: "test",
+			Roots:    &roots,
+		},
+		Data: data,
+	}
+	for path, content := range modules {
+		b.Modules = append(b.Modules, bundle.ModuleFile{
+			URL:    path,
+			Path:   path,
+			Raw:    []byte(content),
+			Parsed: ast.MustParseModule(content),
+		})
+	}
+	if signingKey != nil {
+		private := pem.EncodeToMemory(&pem.Block{
+			Type:  "RSA PRIVATE KEY",
+			Bytes: x509.MarshalPKCS1PrivateKey(signingKey),
+		})
+		require.NoError(t, b.GenerateSignature(bundle.NewSigningConfig(string(private), "RS256", ""), bundleKeyID, false))
+	}
+	var buf bytes.Buffer
+	require.NoError(t, bundle.NewWriter(&buf).Write(b))
+	return buf.Bytes()
+}
+
+func writePublicKey(t *testing.T, key *rsa.PrivateKey) string {
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "public.pem")
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o600))
+	return path
+}
+
+const bundlePolicy = `
+package misscan.bundled
+
+deny {
+    input.evil == data.bundled.settings.evil
+}
+`
+
+func Test_RegoScanning_WithPolicyBundle(t *testing.T) {
+
+	srcFS := CreateFS(t, map[string]string{
+		"bundles/bundle.tar.gz": string(buildBundle(t, []string{"misscan/bundled", "bundled"},
+			map[string]string{"/policies/bundled.rego": bundlePolicy},
+			map[string]interface{}{
+				"bundled": map[string]interface{}{
+					"settings": map[string]interface{}{"evil": "yes"},
+				},
+			},
+			nil,
+		)),
+	})
+
+	scanner := NewScanner(types.SourceJSON, options.ScannerWithPolicyBundle("bundles/bundle.tar.gz"))
+	require.NoError(
+		t,
+		scanner.LoadPolicies(false, false, srcFS, nil, nil),
+	)
+	assert.Contains(t, scanner.policies, "bundles/bundle.tar.gz/policies/bundled.rego")
+
+	results, err := scanner.ScanInput(context.TODO(), Input{
+		Path: "/evil.lol",
+		Contents: map[string]interface{}{
+			"evil": "yes",
+		},
+	})
+	require.NoError(t, err)
+	assert.Len(t, results.GetFailed(), 1)
+}
+
+func Test_RegoScanning_WithOverlappingPolicyBundles(t *testing.T) {
+
+	srcFS := CreateFS(t, map[string]string{
+		"bundles/a.tar.gz": string(buildBundle(t, []string{"misscan"},
+			map[string]string{"/a.rego": "package misscan.a\n\ndeny { input.a }\n"}, nil, nil,
+		)),
+		"bundles/b.tar.gz": string(buildBundle(t, []string{"misscan/b"},
+			map[string]string{"/b.rego": "package misscan.b\n\ndeny { input.b }\n"}, nil, nil,
+		)),
+	})
+
+	scanner := NewScanner(types.SourceJSON, options.ScannerWithPolicyBundle("bundles/a.tar.gz", "bundles/b.tar.gz"))
+	assert.ErrorContains(
+		t,
+		scanner.LoadPolicies(false, false, srcFS, nil, nil),
+		`root "misscan/b" overlaps with root "misscan"`,
+	)
+}
+
+func Test_RegoScanning_WithSignedPolicyBundle(t *testing.T) {
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	modules := map[string]string{"/policies/bundled.rego": bundlePolicy}
+	srcFS := CreateFS(t, map[string]string{
+		"signed.tar.gz":   string(buildBundle(t, []string{"misscan/bundled"}, modules, nil, key)),
+		"unsigned.tar.gz": string(buildBundle(t, []string{"misscan/bundled"}, modules, nil, nil)),
+		"other.tar.gz":    string(buildBundle(t, []string{"misscan/bundled"}, modules, nil, other)),
+	})
+	publicKey := writePublicKey(t, key)
+	secret := filepath.Join(t.TempDir(), "secret")
+	require.NoError(t, os.WriteFile(secret, []byte("not a key"), 0o600))
+	corrupt := filepath.Join(t.TempDir(), "corrupt.pem")
+	require.NoError(t, os.WriteFile(corrupt, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: []byte("garbage")}), 0o600))
+
+	tests := []struct {
+		name    string
+		bundle  string
+		key     string
+		wantErr string
+	}{
+		{
+			name:   "signed with trusted key",
+			bundle: "signed.tar.gz",
+		},
+		{
+			name:    "unsigned",
+			bundle:  "unsigned.tar.gz",
+			wantErr: "bundle missing .signatures.json file",
+		},
+		{
+			name:    "signed with untrusted key",
+			bundle:  "other.tar.gz",
+			wantErr: "verification error",
+		},
+		{
+			name:    "key is not PEM encoded",
+			bundle:  "signed.tar.gz",
+			key:     secret,
+			wantErr: "key is not PEM encoded",
+		},
+		{
+			name:    "key cannot be parsed",
+			bundle:  "signed.tar.gz",
+			key:     corrupt,
+			wantErr: "failed to parse public key",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := publicKey
+			if tt.key != "" {
+				key = tt.key
+			}
+			scanner := NewScanner(
+				types.SourceJSON,
+				options.ScannerWithPolicyBundle(tt.bundle),
+				options.ScannerWithPolicyBundleVerificationKey(key),
+			)
+			err := scanner.LoadPolicies(false, false, srcFS, nil, nil)
+			if tt.wantErr != "" {
+				require.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Len(t, scanner.policies, 1)
+		})
+	}
+}
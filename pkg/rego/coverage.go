@@ -0,0 +1,156 @@
+package rego
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/open-policy-agent/opa/cover"
+	"github.com/open-policy-agent/opa/topdown"
+)
+
+// coverageTracer wraps an OPA coverage tracer so that it can be shared by concurrent evaluations
+type coverageTracer struct {
+	mu    sync.Mutex
+	cover *cover.Cover
+}
+
+func newCoverageTracer() *coverageTracer {
+	return &coverageTracer{
+		cover: cover.New(),
+	}
+}
+
+func (t *coverageTracer) Enabled() bool {
+	return true
+}
+
+func (t *coverageTracer) Config() topdown.TraceConfig {
+	return t.cover.Config()
+}
+
+func (t *coverageTracer) TraceEvent(event topdown.Event) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.cover.TraceEvent(event)
+}
+
+func (t *coverageTracer) report(modules map[string]*ast.Module) cover.Report {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.cover.Report(modules)
+}
+
+type CoverageReport struct {
+	Modules         []ModuleCoverage `json:"modules"`
+	CoveredLines    int              `json:"covered_lines"`
+	NotCoveredLines int              `json:"not_covered_lines"`
+	Coverage        float64          `json:"coverage"`
+
+	modules map[string]*ast.Module
+}
+
+type ModuleCoverage struct {
+	Filename       string  `json:"filename"`
+	Package        string  `json:"package"`
+	CoveredLines   []int   `json:"covered_lines"`
+	UncoveredLines []int   `json:"uncovered_lines"`
+	Coverage       float64 `json:"coverage"`
+}
+
+// NewCoverageReport converts an OPA coverage report into a per-module report of covered and uncovered lines
+func NewCoverageReport(report cover.Report, modules map[string]*ast.Module) *CoverageReport {
+	converted := &CoverageReport{
+		CoveredLines:    report.CoveredLines,
+		NotCoveredLines: report.NotCoveredLines,
+		Coverage:        report.Coverage,
+		modules:         modules,
+	}
+
+	filenames := make([]string, 0, len(modules))
+	for filename := range modules {
+		filenames = append(filenames, filename)
+	}
+	sort.Strings(filenames)
+
+	for _, filename := range filenames {
+		moduleCoverage := ModuleCoverage{
+			Filename:       filename,
+			Package:        modules[filename].Package.Path.String(),
+			CoveredLines:   []int{},
+			UncoveredLines: []int{},
+		}
+		if fileReport, ok := report.Files[filename]; ok {
+			moduleCoverage.CoveredLines = expandRanges(fileReport.Covered)
+			moduleCoverage.UncoveredLines = expandRanges(fileReport.NotCovered)
+			moduleCoverage.Coverage = fileReport.Coverage
+		}
+		converted.Modules = append(converted.Modules, moduleCoverage)
+	}
+
+	return converted
+}
+
+func expandRanges(ranges []cover.Range) []int {
+	lines := []int{}
+	for _, r := range ranges {
+		for row := r.Start.Row; row <= r.End.Row; row++ {
+			lines = append(lines, row)
+		}
+	}
+	return lines
+}
+
+func (r *CoverageReport) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+// WriteText writes the source of each rule, marking covered lines with '+' and uncovered lines with '-'
+func (r *CoverageReport) WriteText(w io.Writer) error {
+	for _, moduleCoverage := range r.Modules {
+		if _, err := fmt.Fprintf(w, "%s (%s) %.2f%%\n", moduleCoverage.Filename, moduleCoverage.Package, moduleCoverage.Coverage); err != nil {
+			return err
+		}
+
+		covered := make(map[int]struct{})
+		for _, line := range moduleCoverage.CoveredLines {
+			covered[line] = struct{}{}
+		}
+		uncovered := make(map[int]struct{})
+		for _, line := range moduleCoverage.UncoveredLines {
+			uncovered[line] = struct{}{}
+		}
+
+		module, ok := r.modules[moduleCoverage.Filename]
+		if !ok {
+			continue
+		}
+		for _, rule := range module.Rules {
+			if rule.Location == nil {
+				continue
+			}
+			for i, line := range strings.Split(string(rule.Location.Text), "\n") {
+				row := rule.Location.Row + i
+				marker := " "
+				if _, ok := covered[row]; ok {
+					marker = "+"
+				} else if _, ok := uncovered[row]; ok {
+					marker = "-"
+				}
+				if _, err := fmt.Fprintf(w, "%s %5d | %s\n", marker, row, line); err != nil {
+					return err
+				}
+			}
+		}
+		if _, err := fmt.Fprintln(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package rego
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/khulnasoft-lab/misscan/pkg/scanners/options"
+	"github.com/khulnasoft-lab/misscan/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_RegoScanning_WithCoverage(t *testing.T) {
+
+	srcFS := CreateFS(t, map[string]string{
+		"policies/test.rego": `package misscan.test
+
+deny[res] {
+    input.evil
+    res := result.new("evil", input)
+}
+
+deny[res] {
+    input.never
+    res := result.new("never", input)
+}
+`,
+	})
+
+	scanner := NewScanner(types.SourceJSON, options.ScannerWithCoverage(true), options.ScannerWithConcurrency(2))
+	require.NoError(
+		t,
+		scanner.LoadPolicies(false, false, srcFS, []string{"policies"}, nil),
+	)
+
+	for _, evil := range []bool{true, false} {
+		_, err := scanner.ScanInput(context.TODO(), Input{
+			Path: "/evil.lol",
+			Contents: map[string]interface{}{
+				"evil": evil,
+			},
+		})
+		require.NoError(t, err)
+	}
+
+	report := scanner.Coverage()
+	require.NotNil(t, report)
+	require.Len(t, report.Modules, 1)
+
+	module := report.Modules[0]
+	assert.Equal(t, "policies/test.rego", module.Filename)
+	assert.Equal(t, "data.misscan.test", module.Package)
+	assert.Equal(t, []int{3, 4, 5, 9}, module.CoveredLines)
+	assert.Equal(t, []int{8, 10}, module.UncoveredLines)
+
+	var buf bytes.Buffer
+	require.NoError(t, report.WriteJSON(&buf))
+	var decoded CoverageReport
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &decoded))
+	assert.Equal(t, report.Modules, decoded.Modules)
+
+	buf.Reset()
+	require.NoError(t, report.WriteText(&buf))
+	assert.Contains(t, buf.String(), "+     4 |     input.evil")
+	assert.Contains(t, buf.String(), "-    10 |     res := result.new(\"never\", input)")
+	assert.Contains(t, buf.String(), "      6 | }")
+}
+
+func Test_RegoScanning_CoverageDisabledByDefault(t *testing.T) {
+	scanner := NewScanner(types.SourceJSON)
+	assert.Nil(t, scanner.Coverage())
+}
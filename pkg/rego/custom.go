@@ -1,6 +1,13 @@
 package rego
 
 import (
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"github.com/liamg/iamgo"
 	"github.com/open-policy-agent/opa/ast"
 	"github.com/open-policy-agent/opa/rego"
 	"github.com/open-policy-agent/opa/types"
@@ -26,6 +33,61 @@ func init() {
 			return metadata.Get(ast.StringTerm("managed")), nil
 		},
 	)
+
+	rego.RegisterBuiltin1(&rego.Function{
+		Name: "misscan.cidr.is_public",
+		Decl: types.NewFunction(types.Args(types.S), types.B),
+	},
+		func(_ rego.BuiltinContext, cidr *ast.Term) (*ast.Term, error) {
+			s, ok := cidr.Value.(ast.String)
+			if !ok {
+				return nil, fmt.Errorf("cidr must be a string")
+			}
+			public, err := isPublicCIDR(string(s))
+			if err != nil {
+				return nil, err
+			}
+			return ast.BooleanTerm(public), nil
+		},
+	)
+
+	rego.RegisterBuiltin2(&rego.Function{
+		Name: "misscan.ports.overlaps",
+		Decl: types.NewFunction(types.Args(types.A, types.A), types.B),
+	},
+		func(_ rego.BuiltinContext, a, b *ast.Term) (*ast.Term, error) {
+			first, err := parsePortRange(a)
+			if err != nil {
+				return nil, err
+			}
+			second, err := parsePortRange(b)
+			if err != nil {
+				return nil, err
+			}
+			return ast.BooleanTerm(first.from <= second.to && second.from <= first.to), nil
+		},
+	)
+
+	rego.RegisterBuiltin3(&rego.Function{
+		Name: "misscan.iam.allows",
+		Decl: types.NewFunction(types.Args(types.A, types.S, types.S), types.B),
+	},
+		func(_ rego.BuiltinContext, doc, action, resource *ast.Term) (*ast.Term, error) {
+			document, err := parseIAMDocument(doc)
+			if err != nil {
+				return nil, err
+			}
+			a, ok := action.Value.(ast.String)
+			if !ok {
+				return nil, fmt.Errorf("action must be a string")
+			}
+			r, ok := resource.Value.(ast.String)
+			if !ok {
+				return nil, fmt.Errorf("resource must be a string")
+			}
+			return ast.BooleanTerm(iamAllows(document, string(a), string(r))), nil
+		},
+	)
 }
 
 func createResult(ctx rego.BuiltinContext, msg, cause *ast.Term) (*ast.Term, error) {
@@ -107,3 +169,225 @@ func updateMetadata(metadata map[string]*ast.Term, input *ast.Term) map[string]*
 	}
 	return metadata
 }
+
+// nonPublicPrefixes are the private, loopback, link-local, shared, documentation, multicast and reserved ranges
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("10.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("127.0.0.0/8"),
+	netip.MustParsePrefix("169.254.0.0/16"),
+	netip.MustParsePrefix("172.16.0.0/12"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("192.0.2.0/24"),
+	netip.MustParsePrefix("192.168.0.0/16"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("198.51.100.0/24"),
+	netip.MustParsePrefix("203.0.113.0/24"),
+	netip.MustParsePrefix("224.0.0.0/4"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("::/128"),
+	netip.MustParsePrefix("::1/128"),
+	netip.MustParsePrefix("2001:db8::/32"),
+	netip.MustParsePrefix("fc00::/7"),
+	netip.MustParsePrefix("fe80::/10"),
+	netip.MustParsePrefix("ff00::/8"),
+}
+
+// isPublicCIDR returns true if a CIDR block, or a single address, includes any publicly routable address
+func isPublicCIDR(cidr string) (bool, error) {
+	cidr = strings.TrimSpace(cidr)
+	prefix, err := netip.ParsePrefix(cidr)
+	if err != nil {
+		addr, addrErr := netip.ParseAddr(cidr)
+		if addrErr != nil {
+			return false, fmt.Errorf("invalid cidr %q", cidr)
+		}
+		prefix = netip.PrefixFrom(addr, addr.BitLen())
+	}
+	if prefix.Addr().Is4In6() {
+		prefix = netip.PrefixFrom(prefix.Addr().Unmap(), max(prefix.Bits()-96, 0))
+	}
+	prefix = prefix.Masked()
+	for _, private := range nonPublicPrefixes {
+		if private.Bits() <= prefix.Bits() && private.Contains(prefix.Addr()) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+type portRange struct {
+	from, to int
+}
+
+const maxPort = 65535
+
+// parsePortRange reads a port or range of ports from a number, a two element array, or a string such as "22",
+// "1024-2048" or "*". A port of -1 means every port, as in AWS security group rules.
+func parsePortRange(term *ast.Term) (portRange, error) {
+	switch value := term.Value.(type) {
+	case ast.Number:
+		port, ok := value.Int()
+		if !ok {
+			return portRange{}, fmt.Errorf("invalid port %s", value)
+		}
+		return newPortRange(port, port)
+	case ast.String:
+		s := strings.TrimSpace(string(value))
+		if s == "*" {
+			return portRange{from: 0, to: maxPort}, nil
+		}
+		if from, to, ok := strings.Cut(s, "-"); ok && from != "" {
+			start, err := strconv.Atoi(strings.TrimSpace(from))
+			if err != nil {
+				return portRange{}, fmt.Errorf("invalid port range %q", s)
+			}
+			end, err := strconv.Atoi(strings.TrimSpace(to))
+			if err != nil {
+				return portRange{}, fmt.Errorf("invalid port range %q", s)
+			}
+			return newPortRange(start, end)
+		}
+		port, err := strconv.Atoi(s)
+		if err != nil {
+			return portRange{}, fmt.Errorf("invalid port %q", s)
+		}
+		return newPortRange(port, port)
+	case *ast.Array:
+		if value.Len() != 2 {
+			return portRange{}, fmt.Errorf("port range must have two elements, got %d", value.Len())
+		}
+		from, err := parsePortRange(value.Elem(0))
+		if err != nil {
+			return portRange{}, err
+		}
+		to, err := parsePortRange(value.Elem(1))
+		if err != nil {
+			return portRange{}, err
+		}
+		return newPortRange(from.from, to.to)
+	default:
+		return portRange{}, fmt.Errorf("port range must be a number, string or array, got %s", ast.TypeName(value))
+	}
+}
+
+func newPortRange(from, to int) (portRange, error) {
+	if from == -1 || to == -1 {
+		return portRange{from: 0, to: maxPort}, nil
+	}
+	if from < 0 || to > maxPort || from > to {
+		return portRange{}, fmt.Errorf("invalid port range %d-%d", from, to)
+	}
+	return portRange{from: from, to: to}, nil
+}
+
+// parseIAMDocument reads a policy document from the object emitted by iam.Document.ToRego, from the JSON of the
+// document, or from the document itself as an object
+func parseIAMDocument(term *ast.Term) (*iamgo.Document, error) {
+	var raw []byte
+	switch value := term.Value.(type) {
+	case ast.String:
+		raw = []byte(value)
+	case ast.Object:
+		if inner := value.Get(ast.StringTerm("value")); inner != nil {
+			s, ok := inner.Value.(ast.String)
+			if !ok {
+				return nil, fmt.Errorf("policy document value must be a string")
+			}
+			raw = []byte(s)
+			break
+		}
+		document, err := ast.JSON(value)
+		if err != nil {
+			return nil, err
+		}
+		if raw, err = json.Marshal(document); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("policy document must be a string or object, got %s", ast.TypeName(value))
+	}
+	return iamgo.Parse(raw)
+}
+
+// iamAllows returns true if the document allows the action on the resource, and no statement denies it. Conditions
+// and principals are not evaluated: a conditional allow is assumed to apply, and a conditional deny is not.
+func iamAllows(document *iamgo.Document, action, resource string) bool {
+	statements, _ := document.Statements()
+	var allowed bool
+	for _, statement := range statements {
+		if !statementMatches(statement, action, resource) {
+			continue
+		}
+		effect, _ := statement.Effect()
+		switch {
+		case strings.EqualFold(effect, iamgo.EffectDeny):
+			if conditions, _ := statement.Conditions(); len(conditions) == 0 {
+				return false
+			}
+		case strings.EqualFold(effect, iamgo.EffectAllow):
+			allowed = true
+		}
+	}
+	return allowed
+}
+
+func statementMatches(statement iamgo.Statement, action, resource string) bool {
+	actions, _ := statement.Actions()
+	notActions, _ := statement.NotActions()
+	resources, _ := statement.Resources()
+	notResources, _ := statement.NotResource()
+	return matchesElement(actions, notActions, action, true) &&
+		matchesElement(resources, notResources, resource, false)
+}
+
+// matchesElement matches a value against an element and its Not variant, e.g. Action and NotAction. Actions are case
+// insensitive, resources are not.
+func matchesElement(patterns, notPatterns []string, value string, foldCase bool) bool {
+	if len(patterns) > 0 {
+		return matchesAnyWildcard(patterns, value, foldCase)
+	}
+	if len(notPatterns) > 0 {
+		return !matchesAnyWildcard(notPatterns, value, foldCase)
+	}
+	return false
+}
+
+func matchesAnyWildcard(patterns []string, value string, foldCase bool) bool {
+	for _, pattern := range patterns {
+		if foldCase {
+			pattern, value = strings.ToLower(pattern), strings.ToLower(value)
+		}
+		if matchesWildcard(pattern, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesWildcard matches a value against an IAM pattern, where * matches any sequence of characters and ? any
+// single character
+func matchesWildcard(pattern, value string) bool {
+	var p, v int
+	star, next := -1, 0
+	for v < len(value) {
+		switch {
+		case p < len(pattern) && (pattern[p] == '?' || pattern[p] == value[v]):
+			p++
+			v++
+		case p < len(pattern) && pattern[p] == '*':
+			star, next = p, v
+			p++
+		case star != -1:
+			next++
+			p, v = star+1, next
+		default:
+			return false
+		}
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
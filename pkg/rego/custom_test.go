@@ -0,0 +1,244 @@
+package rego
+
+import (
+	"context"
+	"testing"
+
+	"github.com/khulnasoft-lab/misscan/pkg/types"
+	"github.com/open-policy-agent/opa/rego"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func evalBuiltin(t *testing.T, query string) (interface{}, bool) {
+	set, err := rego.New(rego.Query(query)).Eval(context.TODO())
+	require.NoError(t, err)
+	if len(set) == 0 {
+		return nil, false
+	}
+	return set[0].Expressions[0].Value, true
+}
+
+func Test_CIDRIsPublic(t *testing.T) {
+	tests := []struct {
+		cidr    string
+		public  bool
+		invalid bool
+	}{
+		{cidr: "0.0.0.0/0", public: true},
+		{cidr: "8.8.8.8", public: true},
+		{cidr: "8.8.8.0/24", public: true},
+		{cidr: "10.0.0.0/8", public: false},
+		{cidr: "10.1.2.0/24", public: false},
+		{cidr: "8.0.0.0/4", public: true},
+		{cidr: "172.16.0.0/12", public: false},
+		{cidr: "172.0.0.0/8", public: true},
+		{cidr: "192.168.1.1", public: false},
+		{cidr: "127.0.0.1/32", public: false},
+		{cidr: "169.254.169.254", public: false},
+		{cidr: "::/0", public: true},
+		{cidr: "::1", public: false},
+		{cidr: "fd00::/8", public: false},
+		{cidr: "2600::/16", public: true},
+		{cidr: "::ffff:10.0.0.1", public: false},
+		{cidr: "not-a-cidr", invalid: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.cidr, func(t *testing.T) {
+			value, ok := evalBuiltin(t, `misscan.cidr.is_public("`+test.cidr+`")`)
+			if test.invalid {
+				assert.False(t, ok)
+				return
+			}
+			require.True(t, ok)
+			assert.Equal(t, test.public, value)
+		})
+	}
+}
+
+func Test_PortsOverlap(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		overlaps bool
+		invalid  bool
+	}{
+		{name: "same port", query: `misscan.ports.overlaps(22, 22)`, overlaps: true},
+		{name: "different port", query: `misscan.ports.overlaps(22, 3389)`, overlaps: false},
+		{name: "range covers port", query: `misscan.ports.overlaps([0, 1024], 22)`, overlaps: true},
+		{name: "range excludes port", query: `misscan.ports.overlaps([1024, 2048], 3389)`, overlaps: false},
+		{name: "string range", query: `misscan.ports.overlaps("3000-4000", "3389")`, overlaps: true},
+		{name: "adjacent ranges", query: `misscan.ports.overlaps("20-21", [22, 23])`, overlaps: false},
+		{name: "all ports", query: `misscan.ports.overlaps(-1, 3389)`, overlaps: true},
+		{name: "wildcard", query: `misscan.ports.overlaps("*", 22)`, overlaps: true},
+		{name: "reversed range", query: `misscan.ports.overlaps([30, 20], 22)`, invalid: true},
+		{name: "out of range", query: `misscan.ports.overlaps(70000, 22)`, invalid: true},
+		{name: "unsupported type", query: `misscan.ports.overlaps({"port": 22}, 22)`, invalid: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			value, ok := evalBuiltin(t, test.query)
+			if test.invalid {
+				assert.False(t, ok)
+				return
+			}
+			require.True(t, ok)
+			assert.Equal(t, test.overlaps, value)
+		})
+	}
+}
+
+func Test_IAMAllows(t *testing.T) {
+	tests := []struct {
+		name     string
+		document string
+		action   string
+		resource string
+		allows   bool
+	}{
+		{
+			name:     "full wildcard",
+			document: `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":"*","Resource":"*"}]}`,
+			action:   "*",
+			resource: "*",
+			allows:   true,
+		},
+		{
+			name:     "service wildcard does not allow every action",
+			document: `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":"s3:*","Resource":"*"}]}`,
+			action:   "*",
+			resource: "*",
+			allows:   false,
+		},
+		{
+			name:     "service wildcard allows a service action",
+			document: `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":["s3:Get*"],"Resource":"arn:aws:s3:::bucket/*"}]}`,
+			action:   "S3:GetObject",
+			resource: "arn:aws:s3:::bucket/key",
+			allows:   true,
+		},
+		{
+			name:     "resource does not match",
+			document: `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":["s3:Get*"],"Resource":"arn:aws:s3:::bucket/*"}]}`,
+			action:   "s3:GetObject",
+			resource: "arn:aws:s3:::other/key",
+			allows:   false,
+		},
+		{
+			name: "deny overrides allow",
+			document: `{"Version":"2012-10-17","Statement":[
+				{"Effect":"Allow","Action":"*","Resource":"*"},
+				{"Effect":"Deny","Action":"iam:*","Resource":"*"}
+			]}`,
+			action:   "iam:CreateUser",
+			resource: "*",
+			allows:   false,
+		},
+		{
+			name: "conditional deny does not override allow",
+			document: `{"Version":"2012-10-17","Statement":[
+				{"Effect":"Allow","Action":"*","Resource":"*"},
+				{"Effect":"Deny","Action":"*","Resource":"*","Condition":{"Bool":{"aws:MultiFactorAuthPresent":"false"}}}
+			]}`,
+			action:   "iam:CreateUser",
+			resource: "*",
+			allows:   true,
+		},
+		{
+			name:     "not action",
+			document: `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","NotAction":"iam:*","Resource":"*"}]}`,
+			action:   "ec2:RunInstances",
+			resource: "*",
+			allows:   true,
+		},
+		{
+			name:     "not action excludes",
+			document: `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","NotAction":"iam:*","Resource":"*"}]}`,
+			action:   "iam:CreateUser",
+			resource: "*",
+			allows:   false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			set, err := rego.New(
+				rego.Query(`misscan.iam.allows(input.doc, input.action, input.resource)`),
+				rego.Input(map[string]interface{}{
+					"doc":      map[string]interface{}{"value": test.document},
+					"action":   test.action,
+					"resource": test.resource,
+				}),
+			).Eval(context.TODO())
+			require.NoError(t, err)
+			require.Len(t, set, 1)
+			assert.Equal(t, test.allows, set[0].Expressions[0].Value)
+		})
+	}
+
+	t.Run("document as an object", func(t *testing.T) {
+		value, ok := evalBuiltin(t, `misscan.iam.allows({"Statement": [{"Effect": "Allow", "Action": "*", "Resource": "*"}]}, "*", "*")`)
+		require.True(t, ok)
+		assert.Equal(t, true, value)
+	})
+
+	t.Run("invalid document", func(t *testing.T) {
+		_, ok := evalBuiltin(t, `misscan.iam.allows({"value": "not json"}, "*", "*")`)
+		assert.False(t, ok)
+	})
+}
+
+func Test_RegoScanning_MisscanBuiltins(t *testing.T) {
+	srcFS := CreateFS(t, map[string]string{
+		"policies/test.rego": `
+# METADATA
+# schemas:
+# - input: schema["cloud"]
+# custom:
+#   avd_id: AVD-TEST-0001
+#   input:
+#     selector:
+#     - type: cloud
+package builtin.aws.ec2.test
+
+deny[res] {
+	rule := input.aws.ec2.securitygroups[_].ingressrules[_]
+	misscan.cidr.is_public(rule.cidrs[_].value)
+	res := result.new("public ingress", rule)
+}
+`,
+	})
+
+	scanner := NewScanner(types.SourceCloud)
+	require.NoError(t, scanner.LoadPolicies(false, false, srcFS, []string{"policies"}, nil))
+
+	results, err := scanner.ScanInput(context.TODO(), Input{
+		Path: "/evil.lol",
+		Contents: map[string]interface{}{
+			"aws": map[string]interface{}{
+				"ec2": map[string]interface{}{
+					"securitygroups": []interface{}{
+						map[string]interface{}{
+							"ingressrules": []interface{}{
+								map[string]interface{}{
+									"cidrs": []interface{}{
+										map[string]interface{}{"value": "10.0.0.0/16"},
+									},
+								},
+								map[string]interface{}{
+									"cidrs": []interface{}{
+										map[string]interface{}{"value": "0.0.0.0/0"},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+	assert.Len(t, results.GetFailed(), 1)
+}
@@ -0,0 +1,318 @@
+package rego
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/open-policy-agent/opa/ast"
+)
+
+// DependencyReport describes the import graph between the packages of a set of modules
+type DependencyReport struct {
+	Packages        []PackageDependencies `json:"packages"`
+	UnusedLibraries []string              `json:"unused_libraries"`
+	MissingImports  []MissingImport       `json:"missing_imports"`
+	Cycles          [][]string            `json:"cycles"`
+}
+
+// PackageDependencies lists the packages a package refers to, and is referred to by. Libraries are packages in the
+// lib namespace.
+type PackageDependencies struct {
+	Package    string   `json:"package"`
+	Library    bool     `json:"library"`
+	Files      []string `json:"files"`
+	Imports    []string `json:"imports"`
+	ImportedBy []string `json:"imported_by"`
+}
+
+// MissingImport is an import of data which does not resolve to any of the packages, although its root namespace is
+// defined by one of them. Imports of other roots, such as data.k8s, are assumed to refer to data documents.
+type MissingImport struct {
+	Filename string `json:"filename"`
+	Row      int    `json:"row"`
+	Package  string `json:"package"`
+	Import   string `json:"import"`
+}
+
+// Failed returns true if there are missing imports or cycles; unused libraries are not a failure
+func (r DependencyReport) Failed() bool {
+	return len(r.MissingImports) > 0 || len(r.Cycles) > 0
+}
+
+func isLibraryPackage(namespace string) bool {
+	return namespace == "lib" || strings.HasPrefix(namespace, "lib.")
+}
+
+type dependencyGraph struct {
+	packages map[string]*PackageDependencies
+	paths    map[string][]string // the components of each package, for resolving references
+	edges    map[string]map[string]struct{}
+}
+
+// AnalyseDependencies builds the import graph between the packages of the modules from their imports and references
+// to data. A package depends on the package a reference resolves to, or on every package under the reference if it
+// refers to a parent of packages, e.g. `import data.lib`.
+func AnalyseDependencies(modules map[string]*ast.Module) *DependencyReport {
+	graph := &dependencyGraph{
+		packages: make(map[string]*PackageDependencies),
+		paths:    make(map[string][]string),
+		edges:    make(map[string]map[string]struct{}),
+	}
+
+	filenames := make([]string, 0, len(modules))
+	for filename := range modules {
+		filenames = append(filenames, filename)
+	}
+	sort.Strings(filenames)
+
+	for _, filename := range filenames {
+		namespace := getModuleNamespace(modules[filename])
+		node, ok := graph.packages[namespace]
+		if !ok {
+			node = &PackageDependencies{
+				Package: namespace,
+				Library: isLibraryPackage(namespace),
+			}
+			graph.packages[namespace] = node
+			graph.paths[namespace] = strings.Split(namespace, ".")
+			graph.edges[namespace] = make(map[string]struct{})
+		}
+		node.Files = append(node.Files, filename)
+	}
+
+	var report DependencyReport
+	for _, filename := range filenames {
+		module := modules[filename]
+		namespace := getModuleNamespace(module)
+
+		for _, imp := range module.Imports {
+			ref, ok := imp.Path.Value.(ast.Ref)
+			if !ok || !ref.HasPrefix(ast.DefaultRootRef) {
+				continue
+			}
+			if len(graph.resolve(ref)) == 0 && graph.definesRoot(ref) {
+				missing := MissingImport{
+					Filename: filename,
+					Package:  namespace,
+					Import:   ref.String(),
+				}
+				if imp.Location != nil {
+					missing.Row = imp.Location.Row
+				}
+				report.MissingImports = append(report.MissingImports, missing)
+			}
+		}
+
+		ast.WalkRefs(module, func(ref ast.Ref) bool {
+			if !ref.HasPrefix(ast.DefaultRootRef) {
+				return false
+			}
+			for _, dependency := range graph.resolve(ref) {
+				if dependency != namespace {
+					graph.edges[namespace][dependency] = struct{}{}
+				}
+			}
+			return false
+		})
+	}
+
+	for namespace, dependencies := range graph.edges {
+		for dependency := range dependencies {
+			graph.packages[namespace].Imports = append(graph.packages[namespace].Imports, dependency)
+			graph.packages[dependency].ImportedBy = append(graph.packages[dependency].ImportedBy, namespace)
+		}
+	}
+
+	namespaces := make([]string, 0, len(graph.packages))
+	for namespace := range graph.packages {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+	for _, namespace := range namespaces {
+		node := graph.packages[namespace]
+		sort.Strings(node.Imports)
+		sort.Strings(node.ImportedBy)
+		report.Packages = append(report.Packages, *node)
+	}
+
+	reachable := graph.reachableFromChecks()
+	for _, namespace := range namespaces {
+		if _, ok := reachable[namespace]; !ok && graph.packages[namespace].Library {
+			report.UnusedLibraries = append(report.UnusedLibraries, namespace)
+		}
+	}
+
+	report.Cycles = graph.cycles(namespaces)
+	return &report
+}
+
+// resolve returns the packages a reference into data refers to: the package with the longest path which prefixes the
+// reference, or otherwise every package whose path the reference prefixes
+func (g *dependencyGraph) resolve(ref ast.Ref) []string {
+	var components []string
+	for _, term := range ref.GroundPrefix()[1:] {
+		s, ok := term.Value.(ast.String)
+		if !ok {
+			break
+		}
+		components = append(components, string(s))
+	}
+	if len(components) == 0 {
+		return nil
+	}
+
+	var longest string
+	var under []string
+	for namespace, path := range g.paths {
+		switch {
+		case hasPathPrefix(components, path):
+			if longest == "" || len(path) > len(g.paths[longest]) {
+				longest = namespace
+			}
+		case hasPathPrefix(path, components):
+			under = append(under, namespace)
+		}
+	}
+	if longest != "" {
+		return []string{longest}
+	}
+	sort.Strings(under)
+	return under
+}
+
+// definesRoot returns true if any package is under the first component of a reference into data
+func (g *dependencyGraph) definesRoot(ref ast.Ref) bool {
+	if len(ref) < 2 {
+		return false
+	}
+	root, ok := ref[1].Value.(ast.String)
+	if !ok {
+		return false
+	}
+	for _, path := range g.paths {
+		if path[0] == string(root) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasPathPrefix(path []string, prefix []string) bool {
+	if len(prefix) > len(path) {
+		return false
+	}
+	for i := range prefix {
+		if path[i] != prefix[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// reachableFromChecks returns every package which is not a library, and every package they depend on
+func (g *dependencyGraph) reachableFromChecks() map[string]struct{} {
+	reachable := make(map[string]struct{})
+	var queue []string
+	for namespace, node := range g.packages {
+		if !node.Library {
+			reachable[namespace] = struct{}{}
+			queue = append(queue, namespace)
+		}
+	}
+	for len(queue) > 0 {
+		namespace := queue[0]
+		queue = queue[1:]
+		for dependency := range g.edges[namespace] {
+			if _, ok := reachable[dependency]; !ok {
+				reachable[dependency] = struct{}{}
+				queue = append(queue, dependency)
+			}
+		}
+	}
+	return reachable
+}
+
+// cycles returns the strongly connected components of the graph with more than one package, using Tarjan's algorithm
+func (g *dependencyGraph) cycles(namespaces []string) [][]string {
+	index := make(map[string]int)
+	lowlink := make(map[string]int)
+	onStack := make(map[string]bool)
+	var stack []string
+	var cycles [][]string
+
+	var connect func(namespace string)
+	connect = func(namespace string) {
+		index[namespace] = len(index)
+		lowlink[namespace] = index[namespace]
+		stack = append(stack, namespace)
+		onStack[namespace] = true
+
+		dependencies := make([]string, 0, len(g.edges[namespace]))
+		for dependency := range g.edges[namespace] {
+			dependencies = append(dependencies, dependency)
+		}
+		sort.Strings(dependencies)
+		for _, dependency := range dependencies {
+			if _, visited := index[dependency]; !visited {
+				connect(dependency)
+				lowlink[namespace] = min(lowlink[namespace], lowlink[dependency])
+			} else if onStack[dependency] {
+				lowlink[namespace] = min(lowlink[namespace], index[dependency])
+			}
+		}
+
+		if lowlink[namespace] != index[namespace] {
+			return
+		}
+		var component []string
+		for {
+			last := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[last] = false
+			component = append(component, last)
+			if last == namespace {
+				break
+			}
+		}
+		if len(component) > 1 {
+			sort.Strings(component)
+			cycles = append(cycles, component)
+		}
+	}
+
+	for _, namespace := range namespaces {
+		if _, visited := index[namespace]; !visited {
+			connect(namespace)
+		}
+	}
+	sort.Slice(cycles, func(i, j int) bool {
+		return cycles[i][0] < cycles[j][0]
+	})
+	return cycles
+}
+
+// Dependencies analyses the import graph of the loaded policies
+func (s *Scanner) Dependencies() *DependencyReport {
+	s.reload.mu.RLock()
+	defer s.reload.mu.RUnlock()
+	return AnalyseDependencies(s.policies)
+}
+
+// trimUnusedLibraries removes library modules which no check depends on, so that they are not compiled
+func (s *Scanner) trimUnusedLibraries() {
+	unused := make(map[string]struct{})
+	for _, namespace := range AnalyseDependencies(s.policies).UnusedLibraries {
+		unused[namespace] = struct{}{}
+	}
+	if len(unused) == 0 {
+		return
+	}
+	var trimmed int
+	for filename, module := range s.policies {
+		if _, ok := unused[getModuleNamespace(module)]; ok {
+			delete(s.policies, filename)
+			trimmed++
+		}
+	}
+	s.debug.Log("Trimmed %d unused library module(s).", trimmed)
+}
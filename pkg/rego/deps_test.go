@@ -0,0 +1,202 @@
+package rego
+
+import (
+	"context"
+	"testing"
+
+	"github.com/khulnasoft-lab/misscan/pkg/scanners/options"
+	"github.com/khulnasoft-lab/misscan/pkg/types"
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func parseModules(t *testing.T, sources map[string]string) map[string]*ast.Module {
+	modules := make(map[string]*ast.Module)
+	for filename, source := range sources {
+		module, err := ast.ParseModuleWithOpts(filename, source, ast.ParserOptions{
+			ProcessAnnotation: true,
+		})
+		require.NoError(t, err)
+		modules[filename] = module
+	}
+	return modules
+}
+
+func Test_AnalyseDependencies(t *testing.T) {
+	modules := parseModules(t, map[string]string{
+		"check.rego": `package builtin.test.TEST001
+
+import data.lib.docker
+import data.lib.missing
+import data.k8s
+
+deny[res] {
+	docker.from[_]
+	res := "fail"
+}`,
+		"parent.rego": `package builtin.test.TEST002
+
+import data.lib
+
+deny[res] {
+	lib.kubernetes.containers[_]
+	res := "fail"
+}`,
+		"docker.rego":     "package lib.docker\n\nfrom[x] { x := input.Stages[_] }",
+		"kubernetes.rego": "package lib.kubernetes\n\nimport data.lib.utils\n\ncontainers[x] { x := utils.all[_] }",
+		"utils.rego":      "package lib.utils\n\nall[x] { x := input[_] }",
+		"unused.rego":     "package lib.unused\n\nnothing { false }",
+		"cycle_a.rego":    "package lib.cycle.a\n\nimport data.lib.cycle.b\n\nx { b.y }",
+		"cycle_b.rego":    "package lib.cycle.b\n\nimport data.lib.cycle.a\n\ny { a.x }",
+	})
+
+	report := AnalyseDependencies(modules)
+
+	packages := make(map[string]PackageDependencies)
+	for _, pkg := range report.Packages {
+		packages[pkg.Package] = pkg
+	}
+	require.Len(t, packages, 8)
+
+	assert.Equal(t, []string{"lib.docker"}, packages["builtin.test.TEST001"].Imports)
+	assert.False(t, packages["builtin.test.TEST001"].Library)
+	assert.Equal(t, []string{"lib.cycle.a", "lib.cycle.b", "lib.docker", "lib.kubernetes", "lib.unused", "lib.utils"},
+		packages["builtin.test.TEST002"].Imports, "importing a parent depends on every package under it")
+	assert.Equal(t, []string{"builtin.test.TEST002", "lib.kubernetes"}, packages["lib.utils"].ImportedBy)
+	assert.True(t, packages["lib.utils"].Library)
+
+	assert.Empty(t, report.UnusedLibraries, "every library is reachable through the parent import")
+
+	require.Len(t, report.MissingImports, 1, "data.k8s is not under a loaded namespace, so is a data document")
+	assert.Equal(t, MissingImport{
+		Filename: "check.rego",
+		Row:      4,
+		Package:  "builtin.test.TEST001",
+		Import:   "data.lib.missing",
+	}, report.MissingImports[0])
+
+	assert.Equal(t, [][]string{{"lib.cycle.a", "lib.cycle.b"}}, report.Cycles)
+	assert.True(t, report.Failed())
+}
+
+func Test_AnalyseDependencies_UnusedLibraries(t *testing.T) {
+	modules := parseModules(t, map[string]string{
+		"check.rego":      "package builtin.test.TEST001\n\nimport data.lib.docker\n\ndeny[res] { docker.from[_]; res := \"fail\" }",
+		"docker.rego":     "package lib.docker\n\nfrom[x] { x := input.Stages[_] }",
+		"kubernetes.rego": "package lib.kubernetes\n\nimport data.lib.utils\n\ncontainers[x] { x := utils.all[_] }",
+		"utils.rego":      "package lib.utils\n\nall[x] { x := input[_] }",
+	})
+
+	report := AnalyseDependencies(modules)
+	assert.Equal(t, []string{"lib.kubernetes", "lib.utils"}, report.UnusedLibraries)
+	assert.Empty(t, report.MissingImports)
+	assert.Empty(t, report.Cycles)
+	assert.False(t, report.Failed())
+}
+
+func Test_RegoScanning_LibraryTrimming(t *testing.T) {
+	srcFS := CreateFS(t, map[string]string{
+		"policies/test.rego": `
+# METADATA
+# custom:
+#   avd_id: AVD-TEST-0001
+#   input:
+#     selector:
+#     - type: dockerfile
+package builtin.dockerfile.test
+
+import data.lib.used
+
+deny[res] {
+	used.stages[_]
+	res := "fail"
+}
+`,
+		"policies/excluded.rego": `
+# METADATA
+# custom:
+#   avd_id: AVD-TEST-0002
+#   input:
+#     selector:
+#     - type: dockerfile
+package builtin.dockerfile.excluded
+
+import data.lib.excluded
+
+deny[res] {
+	excluded.stages[_]
+	res := "fail"
+}
+`,
+		"policies/kubernetes.rego": `
+# METADATA
+# custom:
+#   avd_id: AVD-TEST-0003
+#   input:
+#     selector:
+#     - type: kubernetes
+package builtin.kubernetes.test
+
+import data.lib.kubernetes
+
+deny[res] {
+	kubernetes.containers[_]
+	res := "fail"
+}
+`,
+		"policies/dynamic.rego": `
+package builtin.dockerfile.dynamic
+
+import data.lib.dynamic
+
+__rego_input__ := {"selector": [{"type": input_type}]} {
+	input_type := "dockerfile"
+}
+
+deny[res] {
+	dynamic.stages[_]
+	res := "fail"
+}
+`,
+		"policies/lib/used.rego":       "package lib.used\n\nstages[x] { x := input.Stages[_] }",
+		"policies/lib/dynamic.rego":    "package lib.dynamic\n\nstages[x] { x := input.Stages[_] }",
+		"policies/lib/unused.rego":     "package lib.unused\n\nnothing { false }",
+		"policies/lib/excluded.rego":   "package lib.excluded\n\nstages[x] { x := input.Stages[_] }",
+		"policies/lib/kubernetes.rego": "package lib.kubernetes\n\ncontainers[x] { x := input.spec.containers[_] }",
+	})
+
+	loaded := func(scanner *Scanner) []string {
+		var namespaces []string
+		for _, pkg := range scanner.Dependencies().Packages {
+			namespaces = append(namespaces, pkg.Package)
+		}
+		return namespaces
+	}
+
+	untrimmed := NewScanner(types.SourceDockerfile)
+	require.NoError(t, untrimmed.LoadPolicies(false, false, srcFS, []string{"policies"}, nil))
+	assert.Equal(t, []string{"builtin.dockerfile.dynamic", "builtin.dockerfile.excluded", "builtin.dockerfile.test", "lib.dynamic", "lib.excluded", "lib.kubernetes", "lib.unused", "lib.used"}, loaded(untrimmed))
+
+	// libraries of checks excluded by the filter or selecting other inputs are trimmed along with unused libraries,
+	// before anything is compiled, while checks whose metadata is computed keep their libraries
+	scanner := NewScanner(
+		types.SourceDockerfile,
+		options.ScannerWithLibraryTrimming(true),
+		options.ScannerWithExcludedChecks("AVD-TEST-0002"),
+	)
+	require.NoError(t, scanner.LoadPolicies(false, false, srcFS, []string{"policies"}, nil))
+	assert.Equal(t, []string{"builtin.dockerfile.dynamic", "builtin.dockerfile.test", "lib.dynamic", "lib.used"}, loaded(scanner))
+	assert.Len(t, scanner.compiler.Modules, 4)
+
+	results, err := scanner.ScanInput(context.TODO(), Input{
+		Path: "Dockerfile",
+		Contents: map[string]interface{}{
+			"Stages": []interface{}{
+				map[string]interface{}{"Name": "alpine"},
+			},
+		},
+	})
+	require.NoError(t, err)
+	assert.Len(t, results.GetFailed(), 2)
+}
@@ -0,0 +1,42 @@
+package rego
+
+import (
+	"fmt"
+
+	"github.com/open-policy-agent/opa/ast"
+)
+
+// Diagnostic describes a problem found while compiling policies
+type Diagnostic struct {
+	Filename string `json:"filename"`
+	Row      int    `json:"row"`
+	Col      int    `json:"col"`
+	Code     string `json:"code"`
+	Message  string `json:"message"`
+	Pruned   bool   `json:"pruned"` // the module was removed from the policy set
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s:%d:%d: %s: %s", d.Filename, d.Row, d.Col, d.Code, d.Message)
+}
+
+func newDiagnostic(err *ast.Error) Diagnostic {
+	diagnostic := Diagnostic{
+		Code:    err.Code,
+		Message: err.Message,
+	}
+	if err.Location != nil {
+		diagnostic.Filename = err.Location.File
+		diagnostic.Row = err.Location.Row
+		diagnostic.Col = err.Location.Col
+	}
+	return diagnostic
+}
+
+// Diagnostics returns the compile errors found by the last call to LoadPolicies, including those for modules which
+// were pruned from the policy set
+func (s *Scanner) Diagnostics() []Diagnostic {
+	s.reload.mu.RLock()
+	defer s.reload.mu.RUnlock()
+	return s.diagnostics
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"io/fs"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/khulnasoft-lab/misscan/pkg/rules"
@@ -29,7 +30,16 @@ func init() {
 	RegisterRegoRules(modules)
 }
 
+// RegisterRegoRules registers the checks in modules, all of which are treated as embedded policies
 func RegisterRegoRules(modules map[string]*ast.Module) {
+	RegisterRegoRulesWithSources(modules, nil)
+}
+
+// RegisterRegoRulesWithSources registers the checks in modules, where sources maps the filename of each module to where
+// it was loaded from; modules missing from sources are treated as embedded. When several modules share an AVD ID, only
+// the check from the source with the highest DefaultPolicyPrecedence is registered, the same check which would shadow
+// the others when scanning.
+func RegisterRegoRulesWithSources(modules map[string]*ast.Module, sources map[string]PolicySource) {
 	ctx := context.TODO()
 
 	schemaSet, _, _ := BuildSchemaSetFromPolicies(modules, nil, nil)
@@ -45,15 +55,41 @@ func RegisterRegoRules(modules map[string]*ast.Module) {
 		panic(compiler.Errors)
 	}
 
+	precedence := DefaultPolicyPrecedence()
+	rank := func(filename string) int {
+		source, ok := sources[filename]
+		if !ok {
+			source = PolicySourceEmbedded
+		}
+		return precedenceRank(precedence, source)
+	}
+
+	filenames := make([]string, 0, len(modules))
+	for filename := range modules {
+		filenames = append(filenames, filename)
+	}
+	sort.Slice(filenames, func(i, j int) bool {
+		if ri, rj := rank(filenames[i]), rank(filenames[j]); ri != rj {
+			return ri > rj
+		}
+		return filenames[i] < filenames[j]
+	})
+
 	retriever := NewMetadataRetriever(compiler)
-	for _, module := range modules {
-		metadata, err := retriever.RetrieveMetadata(ctx, module)
+	registered := make(map[string]struct{})
+	for _, filename := range filenames {
+		metadata, err := retriever.RetrieveMetadata(ctx, modules[filename])
 		if err != nil {
 			continue
 		}
 		if metadata.AVDID == "" {
 			continue
 		}
+		// register each check once, even if several modules share its AVD ID
+		if _, ok := registered[metadata.AVDID]; ok {
+			continue
+		}
+		registered[metadata.AVDID] = struct{}{}
 		rules.Register(
 			metadata.ToRule(),
 		)
@@ -69,6 +105,18 @@ func LoadEmbeddedLibraries() (map[string]*ast.Module, error) {
 }
 
 func LoadPoliciesFromDirs(target fs.FS, paths ...string) (map[string]*ast.Module, error) {
+	return loadModulesFromDirs(target, IsRegoFile, nil, paths...)
+}
+
+// LoadPoliciesAndTestsFromDirs loads policies along with their _test.rego files
+func LoadPoliciesAndTestsFromDirs(target fs.FS, paths ...string) (map[string]*ast.Module, error) {
+	return loadModulesFromDirs(target, func(name string) bool {
+		return IsRegoFile(name) || IsRegoTestFile(name)
+	}, nil, paths...)
+}
+
+// loadModulesFromDirs parses the modules found in paths, reusing modules from cache (if not nil) for unchanged files
+func loadModulesFromDirs(target fs.FS, include func(name string) bool, cache *moduleCache, paths ...string) (map[string]*ast.Module, error) {
 	modules := make(map[string]*ast.Module)
 	for _, path := range paths {
 		if err := fs.WalkDir(target, sanitisePath(path), func(path string, info fs.DirEntry, err error) error {
@@ -83,7 +131,15 @@ func LoadPoliciesFromDirs(target fs.FS, paths ...string) (map[string]*ast.Module
 				return fs.SkipDir
 			}
 
-			if !IsRegoFile(info.Name()) || IsDotFile(info.Name()) {
+			if !include(info.Name()) || IsDotFile(info.Name()) {
+				return nil
+			}
+			fileInfo, err := info.Info()
+			if err != nil {
+				return err
+			}
+			if module, ok := cache.get(path, fileInfo); ok {
+				modules[path] = module
 				return nil
 			}
 			data, err := fs.ReadFile(target, filepath.ToSlash(path))
@@ -97,6 +153,7 @@ func LoadPoliciesFromDirs(target fs.FS, paths ...string) (map[string]*ast.Module
 				// s.debug.Log("Failed to load module: %s, err: %s", filepath.ToSlash(path), err.Error())
 				return err
 			}
+			cache.put(path, fileInfo, module)
 			modules[path] = module
 			return nil
 		}); err != nil {
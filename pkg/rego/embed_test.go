@@ -121,3 +121,53 @@ deny[res]{
 		})
 	}
 }
+
+func Test_RegisterRegoRules_DuplicateAVDID(t *testing.T) {
+	module := func(pkg string) *ast.Module {
+		return ast.MustParseModuleWithOpts(`# METADATA
+# title: "dummy title"
+# custom:
+#   avd_id: AVD-TEST-9999
+#   input:
+#     selector:
+#     - type: dockerfile
+package `+pkg+`
+deny[res]{
+	res := true
+}`, ast.ParserOptions{ProcessAnnotation: true})
+	}
+
+	registered := func() []string {
+		var namespaces []string
+		for _, rule := range rules.GetRegistered() {
+			if rule.GetRule().AVDID == "AVD-TEST-9999" {
+				namespaces = append(namespaces, rule.GetRule().RegoPackage)
+			}
+		}
+		return namespaces
+	}
+	require.Empty(t, registered())
+
+	deregister := func() {
+		for _, rule := range rules.GetRegistered() {
+			if rule.GetRule().AVDID == "AVD-TEST-9999" {
+				rules.Deregister(rule)
+			}
+		}
+	}
+	t.Cleanup(deregister)
+
+	modules := map[string]*ast.Module{
+		"a/check.rego": module("builtin.dockerfile.a"),
+		"b/check.rego": module("builtin.dockerfile.b"),
+	}
+	RegisterRegoRules(modules)
+	assert.Equal(t, []string{"data.builtin.dockerfile.a"}, registered(), "modules sharing an AVD ID are registered once")
+	deregister()
+
+	RegisterRegoRulesWithSources(modules, map[string]PolicySource{
+		"a/check.rego": PolicySourceBundle,
+		"b/check.rego": PolicySourceDir,
+	})
+	assert.Equal(t, []string{"data.builtin.dockerfile.b"}, registered(), "the check with the highest precedence is registered")
+}
@@ -15,8 +15,7 @@ func (s *Scanner) isIgnored(ctx context.Context, namespace string, ruleName stri
 }
 
 func (s *Scanner) isNamespaceIgnored(ctx context.Context, namespace string, input interface{}) (bool, error) {
-	exceptionQuery := fmt.Sprintf("data.namespace.exceptions.exception[_] == %q", namespace)
-	result, _, err := s.runQuery(ctx, exceptionQuery, input, true)
+	result, _, err := s.runQuery(ctx, namespaceExceptionQuery(namespace), input, true)
 	if err != nil {
 		return false, fmt.Errorf("query namespace exceptions: %w", err)
 	}
@@ -24,8 +23,7 @@ func (s *Scanner) isNamespaceIgnored(ctx context.Context, namespace string, inpu
 }
 
 func (s *Scanner) isRuleIgnored(ctx context.Context, namespace string, ruleName string, input interface{}) (bool, error) {
-	exceptionQuery := fmt.Sprintf("endswith(%q, data.%s.exception[_][_])", ruleName, namespace)
-	result, _, err := s.runQuery(ctx, exceptionQuery, input, true)
+	result, _, err := s.runQuery(ctx, ruleExceptionQuery(namespace, ruleName), input, true)
 	if err != nil {
 		return false, err
 	}
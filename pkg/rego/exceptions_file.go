@@ -0,0 +1,221 @@
+package rego
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/khulnasoft-lab/misscan/pkg/scan"
+)
+
+// Exception is a declarative waiver for findings, loaded from a YAML or JSON exceptions file:
+//
+//	exceptions:
+//	  - id: AVD-DS-0002
+//	    paths: ["services/legacy/**"]
+//	    resource: aws_s3_bucket.logs
+//	    expires: 2025-06-30
+//	    owner: platform-team
+//	    justification: legacy image, replaced in Q3
+type Exception struct {
+	ID            string   `yaml:"id" json:"id,omitempty"`               // AVD ID, alias or long ID of the rule
+	Namespace     string   `yaml:"namespace" json:"namespace,omitempty"` // rego namespace, matches nested namespaces too
+	Paths         []string `yaml:"paths" json:"paths,omitempty"`         // file globs, '**' matches any number of directories
+	Resource      string   `yaml:"resource" json:"resource,omitempty"`
+	Expires       string   `yaml:"expires" json:"expires,omitempty"` // YYYY-MM-DD (valid until the end of that day, UTC) or RFC3339
+	Owner         string   `yaml:"owner" json:"owner,omitempty"`
+	Justification string   `yaml:"justification" json:"justification"`
+
+	expiry *time.Time // the first instant at which the exception no longer applies
+	line   int
+}
+
+// UnmarshalYAML records the line of the exception in the file, so that expired exceptions can be reported against it
+func (e *Exception) UnmarshalYAML(node *yaml.Node) error {
+	type plain Exception
+	if err := node.Decode((*plain)(e)); err != nil {
+		return err
+	}
+	e.line = node.Line
+	return nil
+}
+
+type exceptionsFile struct {
+	Exceptions []Exception `yaml:"exceptions" json:"exceptions"`
+}
+
+// LoadExceptions reads and validates an exceptions file. JSON files are also accepted as they are valid YAML.
+func LoadExceptions(filename string) ([]Exception, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var file exceptionsFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse exceptions file %s: %w", filename, err)
+	}
+	for i := range file.Exceptions {
+		if err := file.Exceptions[i].validate(); err != nil {
+			return nil, fmt.Errorf("invalid exception %d in %s: %w", i, filename, err)
+		}
+	}
+	return file.Exceptions, nil
+}
+
+func (e *Exception) validate() error {
+	if e.ID == "" && e.Namespace == "" {
+		return fmt.Errorf("either id or namespace must be set")
+	}
+	if e.Justification == "" {
+		return fmt.Errorf("justification must be set")
+	}
+	for _, pattern := range e.Paths {
+		if _, err := path.Match(strings.ReplaceAll(pattern, "**", "*"), ""); err != nil {
+			return fmt.Errorf("invalid path %q: %w", pattern, err)
+		}
+	}
+	if e.Expires != "" {
+		if date, err := time.Parse(time.DateOnly, e.Expires); err == nil {
+			expiry := date.AddDate(0, 0, 1)
+			e.expiry = &expiry
+			return nil
+		}
+		if expiry, err := time.Parse(time.RFC3339, e.Expires); err == nil {
+			e.expiry = &expiry
+			return nil
+		}
+		return fmt.Errorf("invalid expiry date %q, expected YYYY-MM-DD or RFC3339", e.Expires)
+	}
+	return nil
+}
+
+// Expired returns true if the exception has an expiry date which has passed. An exception which expires on a date
+// still applies for the whole of that day.
+func (e Exception) Expired(now time.Time) bool {
+	return e.expiry != nil && !now.Before(*e.expiry)
+}
+
+func (e Exception) matches(result scan.Result) bool {
+	if e.ID != "" && !result.Rule().HasID(e.ID) {
+		return false
+	}
+	if e.Namespace != "" && result.RegoNamespace() != e.Namespace &&
+		!strings.HasPrefix(result.RegoNamespace(), e.Namespace+".") {
+		return false
+	}
+	if len(e.Paths) > 0 && !matchesAnyPath(e.Paths, result.Range().GetFilename()) {
+		return false
+	}
+	if e.Resource != "" {
+		root := result.Metadata().Root()
+		if result.Metadata().Reference() != e.Resource && root.Reference() != e.Resource {
+			return false
+		}
+	}
+	return true
+}
+
+func matchesAnyPath(patterns []string, filename string) bool {
+	filename = strings.TrimPrefix(filename, "/")
+	for _, pattern := range patterns {
+		if matchGlob(strings.TrimPrefix(pattern, "/"), filename) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchGlob matches a slash separated path against a glob pattern in which '**' matches zero or more path segments
+func matchGlob(pattern, name string) bool {
+	patternParts := strings.Split(pattern, "/")
+	nameParts := strings.Split(name, "/")
+	var match func(p, n int) bool
+	match = func(p, n int) bool {
+		for ; p < len(patternParts); p++ {
+			if patternParts[p] == "**" {
+				for i := n; i <= len(nameParts); i++ {
+					if match(p+1, i) {
+						return true
+					}
+				}
+				return false
+			}
+			if n >= len(nameParts) {
+				return false
+			}
+			if ok, _ := path.Match(patternParts[p], nameParts[n]); !ok {
+				return false
+			}
+			n++
+		}
+		return n == len(nameParts)
+	}
+	return match(0, 0)
+}
+
+// applyExceptions marks failed results matched by an unexpired exception as ignored
+func (s *Scanner) applyExceptions(results scan.Results) scan.Results {
+	if len(s.exceptions) == 0 {
+		return results
+	}
+	now := time.Now()
+	for i, result := range results {
+		if result.Status() != scan.StatusFailed {
+			continue
+		}
+		for _, exception := range s.exceptions {
+			if !exception.matches(result) {
+				continue
+			}
+			if exception.Expired(now) {
+				s.debug.Log("Exception for %s expired on %s, not ignoring finding in %s", result.Rule().AVDID, exception.Expires, result.Range().GetFilename())
+				continue
+			}
+			results[i].Suppress(scan.Suppression{
+				Justification: exception.Justification,
+				Owner:         exception.Owner,
+				Expires:       exception.Expires,
+				Source:        s.exceptionsFile,
+			})
+			break
+		}
+	}
+	return results
+}
+
+// reportExpiredExceptions records a diagnostic for each loaded exception whose expiry date has passed
+func (s *Scanner) reportExpiredExceptions() {
+	for _, exception := range s.ExpiredExceptions() {
+		target := exception.ID
+		if target == "" {
+			target = exception.Namespace
+		}
+		message := fmt.Sprintf("exception for %s expired on %s", target, exception.Expires)
+		if exception.Owner != "" {
+			message += fmt.Sprintf(" (owner: %s)", exception.Owner)
+		}
+		s.debug.Log("WARNING: %s", message)
+		s.diagnostics = append(s.diagnostics, Diagnostic{
+			Filename: s.exceptionsFile,
+			Row:      exception.line,
+			Code:     "rego_expired_exception",
+			Message:  message,
+		})
+	}
+}
+
+// ExpiredExceptions returns the loaded exceptions whose expiry date has passed
+func (s *Scanner) ExpiredExceptions() []Exception {
+	var expired []Exception
+	now := time.Now()
+	for _, exception := range s.exceptions {
+		if exception.Expired(now) {
+			expired = append(expired, exception)
+		}
+	}
+	return expired
+}
@@ -0,0 +1,198 @@
+package rego
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/khulnasoft-lab/misscan/pkg/scan"
+	"github.com/khulnasoft-lab/misscan/pkg/scanners/options"
+	"github.com/khulnasoft-lab/misscan/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_matchGlob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{pattern: "main.tf", name: "main.tf", want: true},
+		{pattern: "*.tf", name: "modules/main.tf", want: false},
+		{pattern: "**/*.tf", name: "modules/main.tf", want: true},
+		{pattern: "**/*.tf", name: "main.tf", want: true},
+		{pattern: "services/**", name: "services/legacy/Dockerfile", want: true},
+		{pattern: "services/**/Dockerfile", name: "services/a/b/Dockerfile", want: true},
+		{pattern: "services/**/Dockerfile", name: "other/a/Dockerfile", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, matchGlob(tt.pattern, tt.name))
+		})
+	}
+}
+
+func Test_LoadExceptions_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{
+			name:    "no id or namespace",
+			content: "exceptions:\n  - justification: because\n",
+			wantErr: "either id or namespace must be set",
+		},
+		{
+			name:    "no justification",
+			content: "exceptions:\n  - id: AVD-TEST-0001\n",
+			wantErr: "justification must be set",
+		},
+		{
+			name:    "bad expiry",
+			content: "exceptions:\n  - id: AVD-TEST-0001\n    justification: because\n    expires: next week\n",
+			wantErr: "invalid expiry date",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), "exceptions.yaml")
+			require.NoError(t, os.WriteFile(filename, []byte(tt.content), 0o600))
+			_, err := LoadExceptions(filename)
+			require.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func Test_RegoScanning_WithExceptionsFile(t *testing.T) {
+
+	srcFS := CreateFS(t, map[string]string{
+		"policies/test.rego": `# METADATA
+# custom:
+#   avd_id: AVD-TEST-0001
+package misscan.test
+
+deny[res] {
+    input.evil
+    res := result.new("evil", input)
+}
+`,
+		"policies/other.rego": `
+package misscan.other.nested
+
+deny[res] {
+    input.evil
+    res := result.new("evil", input)
+}
+`,
+	})
+
+	tmp := t.TempDir()
+	filename := filepath.Join(tmp, "exceptions.json")
+	require.NoError(t, os.WriteFile(filename, []byte(`{
+  "exceptions": [
+    {
+      "id": "AVD-TEST-0001",
+      "paths": ["legacy/**"],
+      "expires": "2999-01-01",
+      "owner": "platform",
+      "justification": "legacy service"
+    },
+    {
+      "namespace": "misscan.other",
+      "paths": ["expired/**"],
+      "expires": "2000-01-01",
+      "justification": "no longer valid"
+    },
+    {
+      "namespace": "misscan.other",
+      "resource": "my-resource",
+      "justification": "accepted risk"
+    }
+  ]
+}`), 0o600))
+
+	scanner := NewScanner(types.SourceJSON, options.ScannerWithExceptionsFile(filename))
+	require.NoError(
+		t,
+		scanner.LoadPolicies(false, false, srcFS, []string{"policies"}, nil),
+	)
+
+	expired := scanner.ExpiredExceptions()
+	require.Len(t, expired, 1)
+	assert.Equal(t, "no longer valid", expired[0].Justification)
+	assert.Equal(t, []Diagnostic{{
+		Filename: filename,
+		Row:      10,
+		Code:     "rego_expired_exception",
+		Message:  "exception for misscan.other expired on 2000-01-01",
+	}}, scanner.Diagnostics())
+
+	results, err := scanner.ScanInput(context.TODO(),
+		Input{
+			Path:     "legacy/app/config.json",
+			Contents: map[string]interface{}{"evil": true},
+		},
+		Input{
+			Path:     "expired/config.json",
+			Contents: map[string]interface{}{"evil": true},
+		},
+		Input{
+			Path: "current/config.json",
+			Contents: map[string]interface{}{
+				"evil": true,
+				"__misscan_metadata": map[string]interface{}{
+					"resource": "my-resource",
+				},
+			},
+		},
+	)
+	require.NoError(t, err)
+
+	type finding struct {
+		namespace string
+		filename  string
+	}
+	ignored := make(map[finding]*scan.Suppression)
+	for _, result := range results.GetIgnored() {
+		ignored[finding{result.RegoNamespace(), result.Range().GetFilename()}] = result.Suppression()
+	}
+	assert.Len(t, results.GetFailed(), 4)
+	require.Len(t, ignored, 2)
+
+	assert.Equal(t, &scan.Suppression{
+		Justification: "legacy service",
+		Owner:         "platform",
+		Expires:       "2999-01-01",
+		Source:        filename,
+	}, ignored[finding{"misscan.test", "legacy/app/config.json"}])
+	assert.Equal(t, "accepted risk", ignored[finding{"misscan.other.nested", "current/config.json"}].Justification)
+}
+
+func Test_Exception_Expired(t *testing.T) {
+	tests := []struct {
+		expires string
+		now     time.Time
+		want    bool
+	}{
+		{expires: "2026-10-18", now: time.Date(2026, 10, 17, 23, 59, 59, 0, time.UTC), want: false},
+		{expires: "2026-10-18", now: time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC), want: false},
+		{expires: "2026-10-18", now: time.Date(2026, 10, 18, 23, 59, 59, 999999999, time.UTC), want: false},
+		{expires: "2026-10-18", now: time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC), want: true},
+		{expires: "2026-10-18T12:00:00Z", now: time.Date(2026, 10, 18, 11, 59, 59, 0, time.UTC), want: false},
+		{expires: "2026-10-18T12:00:00Z", now: time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC), want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expires+" "+tt.now.Format(time.RFC3339Nano), func(t *testing.T) {
+			exception := Exception{ID: "AVD-TEST-0001", Justification: "because", Expires: tt.expires}
+			require.NoError(t, exception.validate())
+			assert.Equal(t, tt.want, exception.Expired(tt.now))
+		})
+	}
+
+	var never Exception
+	assert.False(t, never.Expired(time.Now()))
+}
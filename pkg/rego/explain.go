@@ -0,0 +1,296 @@
+package rego
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/khulnasoft-lab/misscan/pkg/scan"
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/open-policy-agent/opa/topdown"
+)
+
+// explainTracer records, each time a body of the traced rule succeeds, the value the rule produced and the
+// expressions of the body with the values bound when it succeeded
+type explainTracer struct {
+	path ast.Ref
+
+	mu           sync.Mutex
+	explanations []explained
+}
+
+type explained struct {
+	value       ast.Value
+	explanation scan.Explanation
+	used        bool
+}
+
+// explainer returns a tracer for the rule if explanations are enabled, or nil
+func (s *Scanner) explainer(namespace string, rule string) *explainTracer {
+	if !s.explain {
+		return nil
+	}
+	return &explainTracer{
+		path: ast.MustParseRef(ruleQuery(namespace, rule)),
+	}
+}
+
+func (t *explainTracer) tracers() []topdown.QueryTracer {
+	if t == nil {
+		return nil
+	}
+	return []topdown.QueryTracer{t}
+}
+
+func (t *explainTracer) Enabled() bool {
+	return true
+}
+
+func (t *explainTracer) Config() topdown.TraceConfig {
+	return topdown.TraceConfig{PlugLocalVars: true}
+}
+
+func (t *explainTracer) TraceEvent(evt topdown.Event) {
+	if evt.Op != topdown.ExitOp {
+		return
+	}
+	rule, ok := evt.Node.(*ast.Rule)
+	if !ok || rule.Module == nil || !rule.Path().Equal(t.path) {
+		return
+	}
+
+	produced := rule.Head.Key
+	if produced == nil {
+		produced = rule.Head.Value
+	}
+	if produced == nil {
+		return
+	}
+
+	explanation := scan.Explanation{
+		Rule: t.path.String(),
+	}
+	definitions := generatedDefinitions(rule.Body)
+	for _, expr := range rule.Body {
+		if expr.Generated {
+			continue
+		}
+		explanation.Steps = append(explanation.Steps, explainExpr(&evt, expr, definitions))
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.explanations = append(t.explanations, explained{
+		value:       evt.Plug(produced).Value,
+		explanation: explanation,
+	})
+}
+
+// annotate sets the explanation for the value produced by the rule on its result. Each explanation is only used once,
+// so that identical values produced by different rule bodies are each explained.
+func (t *explainTracer) annotate(result *scan.Result, value interface{}) {
+	if t == nil {
+		return
+	}
+	v, err := ast.InterfaceToValue(value)
+	if err != nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i := range t.explanations {
+		e := &t.explanations[i]
+		if !e.used && e.value.Compare(v) == 0 {
+			e.used = true
+			result.SetExplanation(e.explanation)
+			return
+		}
+	}
+}
+
+// generatedDefinitions maps each variable generated by the compiler to hold the value of a reference or the output of
+// a call to that reference or call, so that they can be shown as written in the policy
+func generatedDefinitions(body ast.Body) map[ast.Var]*ast.Term {
+	definitions := make(map[ast.Var]*ast.Term)
+	for _, expr := range body {
+		if !expr.Generated {
+			continue
+		}
+		terms, ok := expr.Terms.([]*ast.Term)
+		if !ok || len(terms) < 2 {
+			continue
+		}
+		if expr.IsEquality() {
+			if v, ok := terms[1].Value.(ast.Var); ok && v.IsGenerated() {
+				definitions[v] = terms[2]
+			}
+			continue
+		}
+		builtin, ok := ast.BuiltinMap[expr.Operator().String()]
+		if !ok || builtin.Decl == nil || len(terms)-1 != builtin.Decl.Arity()+1 {
+			continue
+		}
+		if v, ok := terms[len(terms)-1].Value.(ast.Var); ok && v.IsGenerated() {
+			definitions[v] = ast.CallTerm(terms[:len(terms)-1]...)
+		}
+	}
+	return definitions
+}
+
+// explainExpr renders an expression in terms of the original variable names and definitions, with the indices of
+// references replaced by the values they were bound to, and records the values of the references and variables it uses
+func explainExpr(evt *topdown.Event, expr *ast.Expr, definitions map[ast.Var]*ast.Term) scan.ExplanationStep {
+	step := scan.ExplanationStep{}
+	if expr.Location != nil {
+		step.Filename = expr.Location.File
+		step.Line = expr.Location.Row
+	}
+
+	rendered := expr.Copy()
+	seen := make(map[string]struct{})
+	record := func(ref string, value ast.Value) {
+		if _, ok := seen[ref]; ok {
+			return
+		}
+		seen[ref] = struct{}{}
+		v, err := ast.JSON(value)
+		if err != nil {
+			return
+		}
+		step.Values = append(step.Values, scan.ExplanationValue{Ref: ref, Value: v})
+	}
+
+	var transformer *ast.GenericTransformer
+	transformer = ast.NewGenericTransformer(func(x interface{}) (interface{}, error) {
+		switch node := x.(type) {
+		case ast.Ref:
+			ref := node.Copy()
+			for i := 1; i < len(ref); i++ {
+				if v, ok := ref[i].Value.(ast.Var); ok {
+					if bound := boundValue(evt, v); bound != nil && ast.IsScalar(bound) {
+						ref[i] = ast.NewTerm(bound)
+					}
+				}
+			}
+			head, _ := ref[0].Value.(ast.Var)
+			if name := originalName(evt, head); name != head {
+				ref[0] = ast.VarTerm(string(name))
+			}
+			if value := resolveRef(evt, node, ref); value != nil {
+				record(ref.String(), value)
+			}
+			return ref, nil
+		case ast.Var:
+			if node.IsWildcard() {
+				return node, nil
+			}
+			name := originalName(evt, node)
+			bound := boundValue(evt, node)
+			if definition, ok := definitions[node]; ok {
+				value, err := ast.Transform(transformer, definition.Copy())
+				if err != nil {
+					return name, nil
+				}
+				if _, ok := value.(ast.Call); ok && bound != nil {
+					record(value.(ast.Value).String(), bound)
+				}
+				return value, nil
+			}
+			if bound == nil {
+				return name, nil
+			}
+			if strings.HasPrefix(string(name), "__") {
+				// the result of a comprehension, which has no name in the policy
+				if ast.IsScalar(bound) {
+					return bound, nil
+				}
+				return name, nil
+			}
+			record(string(name), bound)
+			return name, nil
+		}
+		return x, nil
+	})
+
+	if transformed, err := ast.Transform(transformer, rendered); err == nil {
+		if e, ok := transformed.(*ast.Expr); ok {
+			rendered = e
+		}
+	}
+	step.Expression = renderExpr(rendered, expr.Location)
+	return step
+}
+
+// renderExpr writes comparisons with their infix operators, and reverses the compiler's rewriting of := and == into
+// unification, as the original operators read better
+func renderExpr(expr *ast.Expr, location *ast.Location) string {
+	terms, ok := expr.Terms.([]*ast.Term)
+	if !ok || len(terms) != 3 || len(expr.With) > 0 {
+		return expr.String()
+	}
+
+	var infix string
+	if expr.IsEquality() && location != nil {
+		text := string(location.Text)
+		switch {
+		case strings.Contains(text, ":="):
+			infix = ast.Assign.Infix
+		case strings.Contains(text, "=="):
+			infix = ast.Equal.Infix
+		}
+	}
+	if builtin, ok := ast.BuiltinMap[expr.Operator().String()]; ok && infix == "" {
+		infix = builtin.Infix
+	}
+	if infix == "" {
+		return expr.String()
+	}
+
+	rendered := fmt.Sprintf("%v %s %v", terms[1], infix, terms[2])
+	if expr.Negated {
+		rendered = "not " + rendered
+	}
+	return rendered
+}
+
+// boundValue returns the value bound to a variable when the event was emitted
+func boundValue(evt *topdown.Event, v ast.Var) ast.Value {
+	if evt.Locals == nil {
+		return nil
+	}
+	return evt.Locals.Get(v)
+}
+
+// originalName returns the name a variable was given in the policy, before it was rewritten by the compiler
+func originalName(evt *topdown.Event, v ast.Var) ast.Var {
+	if metadata, ok := evt.LocalMetadata[v]; ok && metadata.Name != "" {
+		return metadata.Name
+	}
+	return v
+}
+
+// resolveRef looks up the value of a ground reference into the input, or into a value bound to a local variable
+func resolveRef(evt *topdown.Event, original ast.Ref, plugged ast.Ref) ast.Value {
+	if !plugged[1:].IsGround() {
+		return nil
+	}
+	var root ast.Value
+	switch {
+	case original[0].Equal(ast.InputRootDocument):
+		if input := evt.Input(); input != nil {
+			root = input.Value
+		}
+	default:
+		if v, ok := original[0].Value.(ast.Var); ok {
+			root = boundValue(evt, v)
+		}
+	}
+	if root == nil {
+		return nil
+	}
+	value, err := root.Find(plugged[1:])
+	if err != nil {
+		return nil
+	}
+	return value
+}
@@ -0,0 +1,86 @@
+package rego
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/khulnasoft-lab/misscan/pkg/scan"
+	"github.com/khulnasoft-lab/misscan/pkg/scanners/options"
+	"github.com/khulnasoft-lab/misscan/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_RegoScanning_Explain(t *testing.T) {
+	srcFS := CreateFS(t, map[string]string{
+		"policies/test.rego": `
+package misscan.test
+
+deny[res] {
+    input.Stages[i].Commands[j].Cmd == "user"
+    input.Stages[i].Commands[j].Value[0] == "root"
+    count(input.Stages) > 1
+    res := sprintf("stage %d runs as root", [i])
+}
+`,
+	})
+
+	contents := map[string]interface{}{
+		"Stages": []interface{}{
+			map[string]interface{}{
+				"Commands": []interface{}{
+					map[string]interface{}{"Cmd": "from", "Value": []interface{}{"golang"}},
+				},
+			},
+			map[string]interface{}{
+				"Commands": []interface{}{
+					map[string]interface{}{"Cmd": "from", "Value": []interface{}{"alpine"}},
+					map[string]interface{}{"Cmd": "user", "Value": []interface{}{"root"}},
+				},
+			},
+		},
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		scanner := NewScanner(types.SourceDockerfile)
+		require.NoError(t, scanner.LoadPolicies(false, false, srcFS, []string{"policies"}, nil))
+
+		results, err := scanner.ScanInput(context.TODO(), Input{Path: "Dockerfile", Contents: contents})
+		require.NoError(t, err)
+		require.Len(t, results.GetFailed(), 1)
+		assert.Nil(t, results.GetFailed()[0].Explanation())
+	})
+
+	t.Run("enabled", func(t *testing.T) {
+		scanner := NewScanner(types.SourceDockerfile, options.ScannerWithExplain(true))
+		require.NoError(t, scanner.LoadPolicies(false, false, srcFS, []string{"policies"}, nil))
+
+		results, err := scanner.ScanInput(context.TODO(), Input{Path: "Dockerfile", Contents: contents})
+		require.NoError(t, err)
+		require.Len(t, results.GetFailed(), 1)
+
+		explanation := results.GetFailed()[0].Explanation()
+		require.NotNil(t, explanation)
+		assert.Equal(t, "data.misscan.test.deny", explanation.Rule)
+		require.Len(t, explanation.Steps, 4)
+
+		assert.Equal(t, scan.ExplanationStep{
+			Expression: `input.Stages[1].Commands[1].Cmd == "user"`,
+			Filename:   "policies/test.rego",
+			Line:       5,
+			Values: []scan.ExplanationValue{
+				{Ref: "input.Stages[1].Commands[1].Cmd", Value: "user"},
+			},
+		}, explanation.Steps[0])
+		assert.Equal(t, `input.Stages[1].Commands[1].Value[0] == "root"`, explanation.Steps[1].Expression)
+		assert.Equal(t, `count(input.Stages) > 1`, explanation.Steps[2].Expression)
+		assert.Contains(t, explanation.Steps[2].Values, scan.ExplanationValue{Ref: "count(input.Stages)", Value: json.Number("2")})
+		assert.Equal(t, `res := sprintf("stage %d runs as root", [i])`, explanation.Steps[3].Expression)
+		assert.Contains(t, explanation.Steps[3].Values, scan.ExplanationValue{Ref: "res", Value: "stage 1 runs as root"})
+
+		assert.Contains(t, explanation.String(), `policies/test.rego:6: input.Stages[1].Commands[1].Value[0] == "root"
+      input.Stages[1].Commands[1].Value[0] = "root"
+`)
+	})
+}
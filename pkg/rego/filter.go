@@ -0,0 +1,105 @@
+package rego
+
+import (
+	"strings"
+
+	"github.com/khulnasoft-lab/misscan/pkg/framework"
+	"github.com/khulnasoft-lab/misscan/pkg/scan"
+	"github.com/khulnasoft-lab/misscan/pkg/severity"
+	"github.com/open-policy-agent/opa/ast"
+)
+
+// PolicyFilter selects which of the loaded policies are evaluated. Policies are filtered when they are loaded, so
+// excluded policies are never evaluated. Libraries are never filtered, and an empty filter selects every policy.
+type PolicyFilter struct {
+	// IncludeIDs limits evaluation to policies matching one of these AVD IDs, aliases or long IDs
+	IncludeIDs []string
+	// ExcludeIDs lists AVD IDs, aliases or long IDs of policies which are not evaluated, even if they are included
+	ExcludeIDs []string
+	// MinimumSeverity excludes policies with a lower severity, and policies without a valid severity
+	MinimumSeverity severity.Severity
+	// IncludeServices limits evaluation to policies for these providers or services, given as "aws" or "aws/s3"
+	IncludeServices []string
+	// ExcludeServices lists providers or services, given as "aws" or "aws/s3", whose policies are not evaluated
+	ExcludeServices []string
+	// Frameworks limits evaluation to policies in any of these frameworks. As for registered rules, policies without
+	// frameworks belong to the default framework and every policy belongs to the "all" framework.
+	Frameworks []framework.Framework
+}
+
+var severityRank = map[severity.Severity]int{
+	severity.Low:      1,
+	severity.Medium:   2,
+	severity.High:     3,
+	severity.Critical: 4,
+}
+
+// Selects returns true if the policy described by the metadata should be evaluated
+func (f PolicyFilter) Selects(metadata *StaticMetadata) bool {
+	rule := metadata.ToRule()
+
+	if len(f.IncludeIDs) > 0 && !hasAnyID(rule, f.IncludeIDs) {
+		return false
+	}
+	if hasAnyID(rule, f.ExcludeIDs) {
+		return false
+	}
+
+	if f.MinimumSeverity != severity.None {
+		sev := severity.StringToSeverity(metadata.Severity)
+		if severityRank[sev] < severityRank[f.MinimumSeverity] {
+			return false
+		}
+	}
+
+	if len(f.IncludeServices) > 0 && !matchesAnyService(rule, f.IncludeServices) {
+		return false
+	}
+	if matchesAnyService(rule, f.ExcludeServices) {
+		return false
+	}
+
+	return len(f.Frameworks) == 0 || inAnyFramework(rule, f.Frameworks)
+}
+
+func hasAnyID(rule scan.Rule, ids []string) bool {
+	for _, id := range ids {
+		if rule.HasID(id) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesAnyService(rule scan.Rule, services []string) bool {
+	for _, entry := range services {
+		provider, service, _ := strings.Cut(strings.ToLower(entry), "/")
+		if provider != string(rule.Provider) {
+			continue
+		}
+		if service == "" || service == rule.Service {
+			return true
+		}
+	}
+	return false
+}
+
+func inAnyFramework(rule scan.Rule, frameworks []framework.Framework) bool {
+	for _, fw := range frameworks {
+		if fw == framework.ALL {
+			return true
+		}
+		if len(rule.Frameworks) == 0 && fw == framework.Default {
+			return true
+		}
+		if _, ok := rule.Frameworks[fw]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// isLibraryModule returns true for modules which are not policies in their own right, and so are never filtered out
+func isLibraryModule(module *ast.Module, metadata *StaticMetadata) bool {
+	return metadata.Library || !hasEnforcedRules(module)
+}
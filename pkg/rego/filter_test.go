@@ -0,0 +1,152 @@
+package rego
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/khulnasoft-lab/misscan/pkg/framework"
+	"github.com/khulnasoft-lab/misscan/pkg/scanners/options"
+	"github.com/khulnasoft-lab/misscan/pkg/severity"
+	"github.com/khulnasoft-lab/misscan/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_RegoScanning_PolicyFilter(t *testing.T) {
+
+	srcFS := CreateFS(t, map[string]string{
+		"policies/lib.rego": `
+package lib.helpers
+
+is_evil {
+    input.evil
+}
+`,
+		"policies/s3.rego": `
+# METADATA
+# custom:
+#   avd_id: AVD-AWS-0001
+#   short_code: no-public-buckets
+#   provider: aws
+#   service: s3
+#   severity: HIGH
+#   aliases: ["aws-s3-legacy"]
+package defsec.aws.s3
+
+import data.lib.helpers
+
+deny {
+    helpers.is_evil
+}
+`,
+		"policies/ec2.rego": `
+# METADATA
+# custom:
+#   avd_id: AVD-AWS-0002
+#   short_code: no-public-ip
+#   provider: aws
+#   service: ec2
+#   severity: LOW
+#   frameworks:
+#     cis-aws-1.2: ["4.1"]
+package defsec.aws.ec2
+
+deny {
+    input.evil
+}
+`,
+		"policies/storage.rego": `
+# METADATA
+# custom:
+#   avd_id: AVD-AZU-0001
+#   short_code: no-public-access
+#   provider: azure
+#   service: storage
+#   severity: CRITICAL
+package defsec.azure.storage
+
+deny {
+    input.evil
+}
+`,
+	})
+
+	tests := []struct {
+		name     string
+		options  []options.ScannerOption
+		expected []string
+	}{
+		{
+			name:     "no filter",
+			expected: []string{"policies/ec2.rego", "policies/lib.rego", "policies/s3.rego", "policies/storage.rego"},
+		},
+		{
+			name:     "include by avd id, alias and long id",
+			options:  []options.ScannerOption{options.ScannerWithIncludedChecks("AVD-AWS-0002", "aws-s3-legacy", "azure-storage-no-public-access")},
+			expected: []string{"policies/ec2.rego", "policies/lib.rego", "policies/s3.rego", "policies/storage.rego"},
+		},
+		{
+			name:     "include by long id",
+			options:  []options.ScannerOption{options.ScannerWithIncludedChecks("aws-ec2-no-public-ip")},
+			expected: []string{"policies/ec2.rego", "policies/lib.rego"},
+		},
+		{
+			name: "exclusion wins over inclusion",
+			options: []options.ScannerOption{
+				options.ScannerWithIncludedChecks("AVD-AWS-0001", "AVD-AWS-0002"),
+				options.ScannerWithExcludedChecks("AVD-AWS-0001"),
+			},
+			expected: []string{"policies/ec2.rego", "policies/lib.rego"},
+		},
+		{
+			name:     "minimum severity",
+			options:  []options.ScannerOption{options.ScannerWithMinimumSeverity(severity.High)},
+			expected: []string{"policies/lib.rego", "policies/s3.rego", "policies/storage.rego"},
+		},
+		{
+			name:     "include provider",
+			options:  []options.ScannerOption{options.ScannerWithIncludedServices("aws")},
+			expected: []string{"policies/ec2.rego", "policies/lib.rego", "policies/s3.rego"},
+		},
+		{
+			name: "include provider, exclude service",
+			options: []options.ScannerOption{
+				options.ScannerWithIncludedServices("aws", "azure/storage"),
+				options.ScannerWithExcludedServices("aws/ec2"),
+			},
+			expected: []string{"policies/lib.rego", "policies/s3.rego", "policies/storage.rego"},
+		},
+		{
+			name:     "default framework",
+			options:  []options.ScannerOption{options.ScannerWithFrameworks(framework.Default)},
+			expected: []string{"policies/lib.rego", "policies/s3.rego", "policies/storage.rego"},
+		},
+		{
+			name:     "specific framework",
+			options:  []options.ScannerOption{options.ScannerWithFrameworks(framework.CIS_AWS_1_2)},
+			expected: []string{"policies/ec2.rego", "policies/lib.rego"},
+		},
+		{
+			name:     "all frameworks",
+			options:  []options.ScannerOption{options.ScannerWithFrameworks(framework.ALL)},
+			expected: []string{"policies/ec2.rego", "policies/lib.rego", "policies/s3.rego", "policies/storage.rego"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			scanner := NewScanner(types.SourceJSON, test.options...)
+			require.NoError(
+				t,
+				scanner.LoadPolicies(false, false, srcFS, []string{"policies"}, nil),
+			)
+
+			var loaded []string
+			for name := range scanner.policies {
+				loaded = append(loaded, name)
+			}
+			sort.Strings(loaded)
+			assert.Equal(t, test.expected, loaded)
+		})
+	}
+}
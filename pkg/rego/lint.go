@@ -0,0 +1,298 @@
+package rego
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/khulnasoft-lab/misscan/pkg/framework"
+	"github.com/khulnasoft-lab/misscan/pkg/providers"
+	"github.com/khulnasoft-lab/misscan/pkg/severity"
+	misscanTypes "github.com/khulnasoft-lab/misscan/pkg/types"
+	"github.com/open-policy-agent/opa/ast"
+)
+
+type LintSeverity string
+
+const (
+	LintError   LintSeverity = "error"
+	LintWarning LintSeverity = "warning"
+)
+
+// LintFinding is a problem with the metadata or structure of a policy
+type LintFinding struct {
+	Filename string       `json:"filename"`
+	Package  string       `json:"package"`
+	Row      int          `json:"row"`
+	Code     string       `json:"code"`
+	Severity LintSeverity `json:"severity"`
+	Message  string       `json:"message"`
+}
+
+func (f LintFinding) String() string {
+	return fmt.Sprintf("%s:%d: %s %s: %s", f.Filename, f.Row, f.Severity, f.Code, f.Message)
+}
+
+type LintReport struct {
+	Findings []LintFinding `json:"findings"`
+}
+
+// Failed returns true if any finding is an error
+func (r LintReport) Failed() bool {
+	for _, finding := range r.Findings {
+		if finding.Severity == LintError {
+			return true
+		}
+	}
+	return false
+}
+
+var avdIDPattern = regexp.MustCompile(`^AVD-[A-Z0-9]+-[0-9]{4,}$`)
+
+var knownFrameworks = map[framework.Framework]struct{}{
+	framework.Default:      {},
+	framework.Experimental: {},
+	framework.CIS_AWS_1_2:  {},
+	framework.CIS_AWS_1_4:  {},
+	framework.ALL:          {},
+}
+
+var knownSelectorTypes = map[string]struct{}{
+	string(misscanTypes.SourceDockerfile): {},
+	string(misscanTypes.SourceKubernetes): {},
+	string(misscanTypes.SourceRbac):       {},
+	string(misscanTypes.SourceCloud):      {},
+	string(misscanTypes.SourceYAML):       {},
+	string(misscanTypes.SourceJSON):       {},
+	string(misscanTypes.SourceTOML):       {},
+}
+
+// LintPolicies checks the metadata of every policy in modules. Libraries - modules in a lib package, marked with
+// `library: true`, or with neither an avd_id nor any deny/warn rules - are compiled but not checked.
+func LintPolicies(ctx context.Context, modules map[string]*ast.Module) (*LintReport, error) {
+
+	schemaSet, _, err := BuildSchemaSetFromPolicies(modules, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var report LintReport
+
+	for _, violation := range DefaultSandbox().Violations(modules) {
+		finding := LintFinding{
+			Filename: violation.Filename,
+			Row:      violation.Row,
+			Code:     "sandbox-violation",
+			Severity: LintError,
+			Message:  fmt.Sprintf("call to %s is blocked by the default sandbox", violation.Builtin),
+		}
+		if module, ok := modules[violation.Filename]; ok {
+			finding.Package = module.Package.Path.String()
+		}
+		report.Findings = append(report.Findings, finding)
+	}
+
+	// compile without the sandbox, so blocked builtins are reported above rather than as undefined functions
+	compiler := newCompiler(schemaSet, Sandbox{})
+	compiler.Compile(modules)
+	if compiler.Failed() {
+		for _, e := range compiler.Errors {
+			diagnostic := newDiagnostic(e)
+			finding := LintFinding{
+				Filename: diagnostic.Filename,
+				Row:      diagnostic.Row,
+				Code:     "compile-error",
+				Severity: LintError,
+				Message:  diagnostic.Message,
+			}
+			if module, ok := modules[diagnostic.Filename]; ok {
+				finding.Package = module.Package.Path.String()
+			}
+			report.Findings = append(report.Findings, finding)
+		}
+		report.sort()
+		return &report, nil
+	}
+
+	filenames := make([]string, 0, len(modules))
+	for filename := range modules {
+		filenames = append(filenames, filename)
+	}
+	sort.Strings(filenames)
+
+	retriever := NewMetadataRetriever(compiler)
+	seen := make(map[string]string)
+	for _, filename := range filenames {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		module := modules[filename]
+		l := &moduleLinter{
+			filename: filename,
+			module:   module,
+			report:   &report,
+		}
+
+		metadata, err := retriever.RetrieveMetadata(ctx, module)
+		if err != nil {
+			l.add("invalid-metadata", LintError, "%s", err)
+			continue
+		}
+		if l.isLibrary(metadata) {
+			continue
+		}
+		l.lint(metadata)
+
+		if metadata.AVDID != "" {
+			if other, ok := seen[metadata.AVDID]; ok {
+				l.add("duplicate-avd-id", LintError, "avd_id %s is already used by %s", metadata.AVDID, other)
+			} else {
+				seen[metadata.AVDID] = filename
+			}
+		}
+	}
+
+	report.sort()
+	return &report, nil
+}
+
+func (r *LintReport) sort() {
+	sort.SliceStable(r.Findings, func(i, j int) bool {
+		a, b := r.Findings[i], r.Findings[j]
+		switch {
+		case a.Filename != b.Filename:
+			return a.Filename < b.Filename
+		case a.Row != b.Row:
+			return a.Row < b.Row
+		case a.Code != b.Code:
+			return a.Code < b.Code
+		default:
+			return a.Message < b.Message
+		}
+	})
+}
+
+type moduleLinter struct {
+	filename string
+	module   *ast.Module
+	report   *LintReport
+}
+
+func (l *moduleLinter) add(code string, sev LintSeverity, format string, args ...any) {
+	finding := LintFinding{
+		Filename: l.filename,
+		Package:  l.module.Package.Path.String(),
+		Code:     code,
+		Severity: sev,
+		Message:  fmt.Sprintf(format, args...),
+	}
+	if l.module.Package.Location != nil {
+		finding.Row = l.module.Package.Location.Row
+	}
+	l.report.Findings = append(l.report.Findings, finding)
+}
+
+func (l *moduleLinter) isLibrary(metadata *StaticMetadata) bool {
+	namespace := getModuleNamespace(l.module)
+	return metadata.Library ||
+		strings.HasPrefix(namespace, "lib.") ||
+		(metadata.AVDID == "" && !hasEnforcedRules(l.module))
+}
+
+func (l *moduleLinter) lint(metadata *StaticMetadata) {
+
+	if !hasEnforcedRules(l.module) {
+		l.add("no-rules", LintError, "policy has no deny or warn rules")
+	}
+
+	switch {
+	case metadata.AVDID == "":
+		l.add("missing-field", LintError, "avd_id is required")
+	case !avdIDPattern.MatchString(metadata.AVDID):
+		l.add("invalid-avd-id", LintError, "avd_id %q does not match AVD-<PROVIDER>-<NNNN>", metadata.AVDID)
+	}
+
+	if metadata.Title == "" || metadata.Title == "N/A" {
+		l.add("missing-field", LintError, "title is required")
+	}
+	if metadata.Description == "" || metadata.Description == fmt.Sprintf("Rego module: %s", metadata.Package) {
+		l.add("missing-field", LintError, "description is required")
+	}
+	if metadata.ShortCode == "" {
+		l.add("missing-field", LintWarning, "short_code is recommended")
+	}
+	if metadata.RecommendedActions == "" {
+		l.add("missing-field", LintWarning, "recommended_action is recommended")
+	}
+
+	sev := severity.Severity(metadata.Severity)
+	if !sev.IsValid() {
+		l.add("invalid-severity", LintError, "severity %q must be one of %v", metadata.Severity, severity.ValidSeverity)
+	}
+
+	if metadata.Provider != "" {
+		if services, ok := cloudServices[providers.Provider(metadata.Provider)]; !ok {
+			l.add("unknown-provider", LintError, "unknown provider %q", metadata.Provider)
+		} else if metadata.Service != "" {
+			if _, ok := services[metadata.Service]; !ok {
+				l.add("unknown-service", LintError, "unknown service %q for provider %q", metadata.Service, metadata.Provider)
+			}
+		}
+	}
+
+	for fw := range metadata.Frameworks {
+		if _, ok := knownFrameworks[fw]; !ok {
+			l.add("unknown-framework", LintWarning, "unknown framework %q", fw)
+		}
+	}
+
+	urls := metadata.References
+	if metadata.PrimaryURL != "" {
+		urls = append([]string{metadata.PrimaryURL}, urls...)
+	}
+	for _, link := range urls {
+		if link == "" {
+			continue // dynamic metadata evaluated without an input
+		}
+		if parsed, err := url.Parse(link); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			l.add("invalid-url", LintError, "%q is not an absolute http(s) URL", link)
+		}
+	}
+
+	l.lintSelectors(metadata.InputOptions.Selectors)
+}
+
+func (l *moduleLinter) lintSelectors(selectors []Selector) {
+	if len(selectors) == 0 {
+		l.add("invalid-selector", LintWarning, "policy has no input selectors and will be applied to every input")
+		return
+	}
+	for _, selector := range selectors {
+		if _, ok := knownSelectorTypes[selector.Type]; !ok {
+			l.add("invalid-selector", LintError, "unknown selector type %q", selector.Type)
+			continue
+		}
+		for _, subtype := range selector.Subtypes {
+			switch selector.Type {
+			case string(misscanTypes.SourceCloud):
+				services, ok := cloudServices[providers.Provider(subtype.Provider)]
+				if !ok {
+					l.add("invalid-subtype", LintError, "unknown provider %q in cloud subtype", subtype.Provider)
+					continue
+				}
+				if _, ok := services[subtype.Service]; !ok {
+					l.add("invalid-subtype", LintError, "unknown service %q for provider %q in cloud subtype", subtype.Service, subtype.Provider)
+				}
+			case string(misscanTypes.SourceKubernetes), string(misscanTypes.SourceRbac):
+				if subtype.Kind == "" {
+					l.add("invalid-subtype", LintError, "kubernetes subtype must specify a kind")
+				}
+			default:
+				l.add("invalid-subtype", LintWarning, "subtypes are not supported for selector type %q", selector.Type)
+			}
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package rego
+
+import (
+	"context"
+	"testing"
+
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_LintPolicies(t *testing.T) {
+
+	modules := map[string]*ast.Module{
+		"policies/aws/s3.rego": ast.MustParseModuleWithOpts(`# METADATA
+# title: Valid
+# description: A valid policy
+# scope: package
+# related_resources:
+# - https://example.com/valid
+# custom:
+#   avd_id: AVD-AWS-0001
+#   provider: aws
+#   service: s3
+#   severity: HIGH
+#   short_code: valid
+#   recommended_action: Fix it
+#   frameworks:
+#     cis-aws-1.4: ["2.1"]
+#   input:
+#     selector:
+#     - type: cloud
+#       subtypes:
+#       - provider: aws
+#         service: s3
+package builtin.aws.s3.aws0001
+
+deny[res] {
+	res := result.new("bad", {})
+}
+`, ast.ParserOptions{ProcessAnnotation: true}),
+		"policies/invalid.rego": ast.MustParseModuleWithOpts(`# METADATA
+# title: Invalid
+# description: An invalid policy
+# scope: package
+# related_resources:
+# - https://example.com/invalid
+# custom:
+#   avd_id: AVD-1
+#   provider: aws
+#   service: nope
+#   severity: FOO
+#   short_code: invalid
+#   recommended_action: Fix it
+#   input:
+#     selector:
+#     - type: terraform
+package builtin.invalid
+
+allow {
+	true
+}
+`, ast.ParserOptions{ProcessAnnotation: true}),
+		"policies/duplicate.rego": ast.MustParseModuleWithOpts(`# METADATA
+# title: Duplicate
+# description: A duplicate policy
+# scope: package
+# custom:
+#   avd_id: AVD-AWS-0001
+#   severity: LOW
+#   short_code: duplicate
+#   recommended_action: Fix it
+#   input:
+#     selector:
+#     - type: kubernetes
+#       subtypes:
+#       - group: apps
+package builtin.duplicate
+
+deny {
+	true
+}
+`, ast.ParserOptions{ProcessAnnotation: true}),
+		"lib/helpers.rego": ast.MustParseModule(`package lib.helpers
+
+is_bad {
+	true
+}
+`),
+	}
+
+	report, err := LintPolicies(context.TODO(), modules)
+	require.NoError(t, err)
+	assert.True(t, report.Failed())
+
+	codes := make(map[string][]string)
+	for _, finding := range report.Findings {
+		codes[finding.Filename] = append(codes[finding.Filename], finding.Code)
+	}
+
+	assert.NotContains(t, codes, "policies/aws/s3.rego")
+	assert.NotContains(t, codes, "lib/helpers.rego")
+	assert.Equal(t, []string{
+		"invalid-avd-id",
+		"invalid-selector",
+		"invalid-severity",
+		"no-rules",
+		"unknown-service",
+	}, codes["policies/invalid.rego"])
+	assert.Equal(t, []string{
+		"duplicate-avd-id",
+		"invalid-subtype",
+	}, codes["policies/duplicate.rego"])
+}
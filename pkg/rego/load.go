@@ -5,14 +5,20 @@ import (
 	"fmt"
 	"io"
 	"io/fs"
+	"maps"
 	"strings"
+	"time"
 
 	"github.com/open-policy-agent/opa/ast"
 	"github.com/open-policy-agent/opa/bundle"
 )
 
 func IsRegoFile(name string) bool {
-	return strings.HasSuffix(name, bundle.RegoExt) && !strings.HasSuffix(name, "_test"+bundle.RegoExt)
+	return strings.HasSuffix(name, bundle.RegoExt) && !IsRegoTestFile(name)
+}
+
+func IsRegoTestFile(name string) bool {
+	return strings.HasSuffix(name, "_test"+bundle.RegoExt)
 }
 
 func IsDotFile(name string) bool {
@@ -40,23 +46,23 @@ func (s *Scanner) loadPoliciesFromReaders(readers []io.Reader) (map[string]*ast.
 
 func (s *Scanner) loadEmbedded(enableEmbeddedLibraries, enableEmbeddedPolicies bool) error {
 	if enableEmbeddedLibraries {
-		loadedLibs, errLoad := LoadEmbeddedLibraries()
+		loadedLibs, errLoad := s.reload.modules.loadEmbedded("libraries", LoadEmbeddedLibraries)
 		if errLoad != nil {
 			return fmt.Errorf("failed to load embedded rego libraries: %w", errLoad)
 		}
 		for name, policy := range loadedLibs {
-			s.policies[name] = policy
+			s.addPolicy(name, policy, PolicySourceEmbedded)
 		}
 		s.debug.Log("Loaded %d embedded libraries.", len(loadedLibs))
 	}
 
 	if enableEmbeddedPolicies {
-		loaded, err := LoadEmbeddedPolicies()
+		loaded, err := s.reload.modules.loadEmbedded("policies", LoadEmbeddedPolicies)
 		if err != nil {
 			return fmt.Errorf("failed to load embedded rego policies: %w", err)
 		}
 		for name, policy := range loaded {
-			s.policies[name] = policy
+			s.addPolicy(name, policy, PolicySourceEmbedded)
 		}
 		s.debug.Log("Loaded %d embedded policies.", len(loaded))
 	}
@@ -66,40 +72,96 @@ func (s *Scanner) loadEmbedded(enableEmbeddedLibraries, enableEmbeddedPolicies b
 
 func (s *Scanner) LoadPolicies(enableEmbeddedLibraries, enableEmbeddedPolicies bool, srcFS fs.FS, paths []string, readers []io.Reader) error {
 
-	if s.policies == nil {
-		s.policies = make(map[string]*ast.Module)
-	}
-
 	if s.policyFS != nil {
 		s.debug.Log("Overriding filesystem for policies!")
 		srcFS = s.policyFS
 	}
 
-	if err := s.loadEmbedded(enableEmbeddedLibraries, enableEmbeddedPolicies); err != nil {
+	args := loadArgs{
+		embeddedLibraries: enableEmbeddedLibraries,
+		embeddedPolicies:  enableEmbeddedPolicies,
+		srcFS:             srcFS,
+		paths:             paths,
+		inputSchema:       s.inputSchema,
+	}
+
+	if len(readers) > 0 {
+		loaded, err := s.loadPoliciesFromReaders(readers)
+		if err != nil {
+			return fmt.Errorf("failed to load rego policies from reader(s): %w", err)
+		}
+		args.readerModules = loaded
+	}
+
+	previous := s.queries
+	err := s.loadPolicies(context.TODO(), args, nil)
+	if previous != nil && previous != s.queries {
+		previous.close()
+	}
+	if err != nil {
 		return err
 	}
+	files, err := s.snapshot(&args)
+	if err != nil {
+		s.debug.Log("Failed to record policy and data files for reloading: %s", err)
+	}
+	args.files = files
+	s.reload.record(args)
+	return nil
+}
+
+// loadPolicies loads and compiles the policies. If previous is not nil, the data has not changed since it was compiled,
+// and only the packages affected by the modules which changed since are compiled again.
+func (s *Scanner) loadPolicies(ctx context.Context, args loadArgs, previous *previousCompile) error {
+
+	if s.policies == nil {
+		s.policies = make(map[string]*ast.Module)
+	}
+
+	if err := s.loadEmbedded(args.embeddedLibraries, args.embeddedPolicies); err != nil {
+		return err
+	}
+
+	srcFS, paths := args.srcFS, args.paths
 
-	var err error
 	if len(paths) > 0 {
-		loaded, err := LoadPoliciesFromDirs(srcFS, paths...)
+		loaded, err := loadModulesFromDirs(srcFS, IsRegoFile, s.reload.modules, paths...)
 		if err != nil {
 			return fmt.Errorf("failed to load rego policies from %s: %w", paths, err)
 		}
 		for name, policy := range loaded {
-			s.policies[name] = policy
+			s.addPolicy(name, policy, PolicySourceDir)
 		}
 		s.debug.Log("Loaded %d policies from disk.", len(loaded))
 	}
 
-	if len(readers) > 0 {
-		loaded, err := s.loadPoliciesFromReaders(readers)
+	if len(args.readerModules) > 0 {
+		for name, policy := range args.readerModules {
+			s.addPolicy(name, policy, PolicySourceReader)
+		}
+		s.debug.Log("Loaded %d policies from reader(s).", len(args.readerModules))
+	}
+
+	if s.exceptionsFile != "" {
+		exceptions, err := LoadExceptions(s.exceptionsFile)
 		if err != nil {
-			return fmt.Errorf("failed to load rego policies from reader(s): %w", err)
+			return err
+		}
+		s.exceptions = exceptions
+		s.debug.Log("Loaded %d exceptions.", len(exceptions))
+	}
+
+	var bundleData map[string]interface{}
+	if len(s.policyBundles) > 0 {
+		loaded, data, err := s.loadPolicyBundles(srcFS)
+		if err != nil {
+			return fmt.Errorf("failed to load rego policies from bundle(s): %w", err)
 		}
 		for name, policy := range loaded {
-			s.policies[name] = policy
+			s.addPolicy(name, policy, PolicySourceBundle)
 		}
-		s.debug.Log("Loaded %d policies from reader(s).", len(loaded))
+		bundleData = data
+		s.debug.Log("Loaded %d policies from bundle(s).", len(loaded))
 	}
 
 	// gather namespaces
@@ -113,34 +175,72 @@ func (s *Scanner) LoadPolicies(enableEmbeddedLibraries, enableEmbeddedPolicies b
 		namespaces = append(namespaces, namespace)
 	}
 
-	dataFS := srcFS
-	if s.dataFS != nil {
-		s.debug.Log("Overriding filesystem for data!")
-		dataFS = s.dataFS
+	if previous != nil && !previous.hasNamespaces(ctx, namespaces) {
+		s.debug.Log("Namespaces changed, compiling all policies.")
+		previous = nil
 	}
-	store, err := initStore(dataFS, s.dataDirs, namespaces)
-	if err != nil {
-		return fmt.Errorf("unable to load data: %w", err)
+	if previous != nil {
+		// the data has not changed, and the queries which are kept were prepared against the current store
+		s.store = previous.store
+	} else {
+		dataFS := srcFS
+		if s.dataFS != nil {
+			s.debug.Log("Overriding filesystem for data!")
+			dataFS = s.dataFS
+		}
+		store, err := initStore(dataFS, s.dataDirs, namespaces, bundleData)
+		if err != nil {
+			return fmt.Errorf("unable to load data: %w", err)
+		}
+		s.store = store
 	}
-	s.store = store
 
-	return s.compilePolicies(srcFS, paths)
+	s.diagnostics = nil
+	s.reportExpiredExceptions()
+	s.shadowPolicies()
+	if err := s.enforceSandbox(); err != nil {
+		return err
+	}
+	start := time.Now()
+	if err := s.compilePolicies(srcFS, paths, previous); err != nil {
+		return err
+	}
+	if err := s.prepareQueries(ctx); err != nil {
+		return err
+	}
+	s.stats.recordCompile(time.Since(start), len(s.policies))
+	return nil
 }
 
 func (s *Scanner) prunePoliciesWithError(compiler *ast.Compiler) error {
-	if len(compiler.Errors) > s.regoErrorLimit {
+	if len(compiler.Errors) > s.regoErrorLimit || s.strict {
+		for _, e := range compiler.Errors {
+			s.diagnostics = append(s.diagnostics, newDiagnostic(e))
+		}
 		s.debug.Log("Error(s) occurred while loading policies")
 		return compiler.Errors
 	}
 
 	for _, e := range compiler.Errors {
-		s.debug.Log("Error occurred while parsing: %s, %s", e.Location.File, e.Error())
-		delete(s.policies, e.Location.File)
+		diagnostic := newDiagnostic(e)
+		s.debug.Log("Error occurred while parsing: %s, %s", diagnostic.Filename, e.Error())
+		if _, ok := s.policies[diagnostic.Filename]; ok {
+			delete(s.policies, diagnostic.Filename)
+			diagnostic.Pruned = true
+		}
+		s.diagnostics = append(s.diagnostics, diagnostic)
 	}
 	return nil
 }
 
-func (s *Scanner) compilePolicies(srcFS fs.FS, paths []string) error {
+func (s *Scanner) compilePolicies(srcFS fs.FS, paths []string, previous *previousCompile) error {
+
+	if s.trimLibraries {
+		// checks and libraries which are known not to be needed are removed before they are compiled
+		s.filterStaticModules()
+		s.trimUnusedLibraries()
+	}
+	s.compiled = maps.Clone(s.policies)
 
 	schemaSet, custom, err := BuildSchemaSetFromPolicies(s.policies, paths, srcFS)
 	if err != nil {
@@ -150,61 +250,114 @@ func (s *Scanner) compilePolicies(srcFS fs.FS, paths []string) error {
 		s.inputSchema = nil // discard auto detected input schema in favour of policy defined schema
 	}
 
-	compiler := ast.NewCompiler().
-		WithUseTypeCheckAnnotations(true).
-		WithCapabilities(ast.CapabilitiesForThisVersion()).
-		WithSchemas(schemaSet)
+	if previous != nil && (s.inputSchema == nil) == (previous.inputSchema == nil) {
+		return s.recompilePolicies(srcFS, paths, schemaSet, previous)
+	}
+
+	compiler := newCompiler(schemaSet, s.sandbox)
 
 	compiler.Compile(s.policies)
 	if compiler.Failed() {
 		if err := s.prunePoliciesWithError(compiler); err != nil {
 			return err
 		}
-		return s.compilePolicies(srcFS, paths)
+		return s.compilePolicies(srcFS, paths, nil)
 	}
 	retriever := NewMetadataRetriever(compiler)
 
-	if err := s.filterModules(retriever); err != nil {
+	filtered, err := s.filterModules(retriever, s.policies)
+	if err != nil {
 		return err
 	}
+	s.policies = filtered
 	if s.inputSchema != nil {
-		schemaSet := ast.NewSchemaSet()
-		schemaSet.Put(ast.MustParseRef("schema.input"), s.inputSchema)
-		compiler.WithSchemas(schemaSet)
+		compiler.WithSchemas(s.inputSchemaSet())
 		compiler.Compile(s.policies)
 		if compiler.Failed() {
 			if err := s.prunePoliciesWithError(compiler); err != nil {
 				return err
 			}
-			return s.compilePolicies(srcFS, paths)
+			return s.compilePolicies(srcFS, paths, nil)
 		}
 	}
 	s.compiler = compiler
+	s.deferred = nil
 	s.retriever = retriever
+	s.queries = newQueryCache() // queries prepared against a previous compiler are no longer valid
 	return nil
 }
 
-func (s *Scanner) filterModules(retriever *MetadataRetriever) error {
+// inputSchemaSet returns the schema set holding the input schema
+func (s *Scanner) inputSchemaSet() *ast.SchemaSet {
+	schemaSet := ast.NewSchemaSet()
+	schemaSet.Put(ast.MustParseRef("schema.input"), s.inputSchema)
+	return schemaSet
+}
+
+// newCompiler creates a compiler configured for misscan policies - shared by the scanner, the test runner and the linter
+func newCompiler(schemaSet *ast.SchemaSet, sandbox Sandbox) *ast.Compiler {
+	return ast.NewCompiler().
+		WithUseTypeCheckAnnotations(true).
+		WithCapabilities(sandbox.capabilities()).
+		WithSchemas(schemaSet)
+}
+
+// filterModules returns the modules which are selected by the policy filter and apply to the source type of the scanner
+func (s *Scanner) filterModules(retriever *MetadataRetriever, modules map[string]*ast.Module) (map[string]*ast.Module, error) {
 
 	filtered := make(map[string]*ast.Module)
-	for name, module := range s.policies {
+	for name, module := range modules {
 		meta, err := retriever.RetrieveMetadata(context.TODO(), module)
 		if err != nil {
-			return err
+			return nil, err
+		}
+		if !s.selectsModule(name, module, meta) {
+			continue
 		}
 		if len(meta.InputOptions.Selectors) == 0 {
 			s.debug.Log("WARNING: Module %s has no input selectors - it will be loaded for all inputs!", name)
-			filtered[name] = module
+		}
+		filtered[name] = module
+	}
+	return filtered, nil
+}
+
+// filterStaticModules removes the modules which filterModules would remove, where this can be decided from their
+// annotations or constant metadata without compiling them. Modules whose metadata cannot be read statically, including
+// modules of packages split across several files, are left for filterModules.
+func (s *Scanner) filterStaticModules() {
+	files := make(map[string]int)
+	for _, module := range s.policies {
+		files[module.Package.Path.String()]++
+	}
+	for name, module := range s.policies {
+		if files[module.Package.Path.String()] > 1 {
 			continue
 		}
-		for _, selector := range meta.InputOptions.Selectors {
-			if selector.Type == string(s.sourceType) {
-				filtered[name] = module
-				break
-			}
+		meta, ok := staticMetadata(module)
+		if !ok {
+			continue
+		}
+		if !s.selectsModule(name, module, meta) {
+			delete(s.policies, name)
 		}
 	}
+}
 
-	s.policies = filtered
-	return nil
+// selectsModule returns true if the module is selected by the policy filter and either has no input selectors or has one
+// for the source type of the scanner
+func (s *Scanner) selectsModule(name string, module *ast.Module, meta *StaticMetadata) bool {
+	if !isLibraryModule(module, meta) && !s.filter.Selects(meta) {
+		s.debug.Log("Policy %s is excluded by the policy filter.", name)
+		return false
+	}
+	if len(meta.InputOptions.Selectors) == 0 {
+		return true
+	}
+	for _, selector := range meta.InputOptions.Selectors {
+		if selector.Type == string(s.sourceType) {
+			return true
+		}
+	}
+	return false
 }
@@ -5,6 +5,7 @@ import (
 	"embed"
 	"testing"
 
+	"github.com/khulnasoft-lab/misscan/pkg/scanners/options"
 	"github.com/khulnasoft-lab/misscan/pkg/types"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -23,7 +24,7 @@ func Test_RegoScanning_WithSomeInvalidPolicies(t *testing.T) {
 		require.NotNil(t, p)
 
 		scanner.policies = p
-		err := scanner.compilePolicies(testEmbedFS, []string{"policies"})
+		err := scanner.compilePolicies(testEmbedFS, []string{"policies"}, nil)
 		require.ErrorContains(t, err, `want (one of): ["Cmd" "EndLine" "Flags" "JSON" "Original" "Path" "Stage" "StartLine" "SubCmd" "Value"]`)
 		assert.Contains(t, debugBuf.String(), "Error(s) occurred while loading policies")
 	})
@@ -37,10 +38,35 @@ func Test_RegoScanning_WithSomeInvalidPolicies(t *testing.T) {
 		p, _ := LoadPoliciesFromDirs(testEmbedFS, ".")
 		scanner.policies = p
 
-		err := scanner.compilePolicies(testEmbedFS, []string{"policies"})
+		err := scanner.compilePolicies(testEmbedFS, []string{"policies"}, nil)
 		require.NoError(t, err)
 
 		assert.Contains(t, debugBuf.String(), "Error occurred while parsing: testdata/policies/invalid.rego, testdata/policies/invalid.rego:7")
+
+		diagnostics := scanner.Diagnostics()
+		require.Len(t, diagnostics, 1)
+		assert.Equal(t, "testdata/policies/invalid.rego", diagnostics[0].Filename)
+		assert.Equal(t, 7, diagnostics[0].Row)
+		assert.Equal(t, "rego_type_error", diagnostics[0].Code)
+		assert.True(t, diagnostics[0].Pruned)
+		assert.NotContains(t, scanner.policies, "testdata/policies/invalid.rego")
+	})
+
+	t.Run("strict compilation", func(t *testing.T) {
+		scanner := NewScanner(types.SourceDockerfile, options.ScannerWithStrictCompilation(true))
+		scanner.SetRegoErrorLimit(1)
+
+		p, _ := LoadPoliciesFromDirs(testEmbedFS, ".")
+		scanner.policies = p
+
+		err := scanner.compilePolicies(testEmbedFS, []string{"policies"}, nil)
+		require.Error(t, err)
+
+		diagnostics := scanner.Diagnostics()
+		require.Len(t, diagnostics, 1)
+		assert.Equal(t, "testdata/policies/invalid.rego", diagnostics[0].Filename)
+		assert.False(t, diagnostics[0].Pruned)
+		assert.Contains(t, scanner.policies, "testdata/policies/invalid.rego")
 	})
 
 }
@@ -80,12 +80,8 @@ func (sm *StaticMetadata) Update(meta map[string]any) error {
 		sm.References = append(sm.References, fmt.Sprintf("%s", raw))
 	}
 	if raw, ok := meta["frameworks"]; ok {
-		frameworks, ok := raw.(map[string][]string)
-		if !ok {
-			return fmt.Errorf("failed to parse framework metadata: not an object")
-		}
-		for fw, sections := range frameworks {
-			sm.Frameworks[framework.Framework(fw)] = sections
+		if err := sm.updateFrameworks(raw); err != nil {
+			return err
 		}
 	}
 	if raw, ok := meta["related_resources"]; ok {
@@ -114,6 +110,31 @@ func (sm *StaticMetadata) Update(meta map[string]any) error {
 	return nil
 }
 
+// updateFrameworks accepts frameworks as decoded from rego values and annotations, where sections are a []interface{}
+func (sm *StaticMetadata) updateFrameworks(raw any) error {
+	switch frameworks := raw.(type) {
+	case map[string][]string:
+		for fw, sections := range frameworks {
+			sm.Frameworks[framework.Framework(fw)] = sections
+		}
+	case map[string]any:
+		for fw, rawSections := range frameworks {
+			list, ok := rawSections.([]any)
+			if !ok {
+				return fmt.Errorf("failed to parse framework metadata: sections of %q are not a list", fw)
+			}
+			sections := make([]string, 0, len(list))
+			for _, section := range list {
+				sections = append(sections, fmt.Sprintf("%s", section))
+			}
+			sm.Frameworks[framework.Framework(fw)] = sections
+		}
+	default:
+		return fmt.Errorf("failed to parse framework metadata: not an object")
+	}
+	return nil
+}
+
 func (sm *StaticMetadata) updateAliases(meta map[string]any) {
 	if raw, ok := meta["aliases"]; ok {
 		if aliases, ok := raw.([]interface{}); ok {
@@ -222,7 +243,8 @@ func (m StaticMetadata) ToRule() scan.Rule {
 }
 
 type MetadataRetriever struct {
-	compiler *ast.Compiler
+	compiler  *ast.Compiler
+	compilers map[string]*ast.Compiler // the compilers of packages which were not recompiled on reload, by namespace
 }
 
 func NewMetadataRetriever(compiler *ast.Compiler) *MetadataRetriever {
@@ -231,8 +253,16 @@ func NewMetadataRetriever(compiler *ast.Compiler) *MetadataRetriever {
 	}
 }
 
+// compilerFor returns the compiler the package of the module was compiled by
+func (m *MetadataRetriever) compilerFor(module *ast.Module) *ast.Compiler {
+	if compiler, ok := m.compilers[getModuleNamespace(module)]; ok {
+		return compiler
+	}
+	return m.compiler
+}
+
 func (m *MetadataRetriever) findPackageAnnotations(module *ast.Module) *ast.Annotations {
-	annotationSet := m.compiler.GetAnnotationSet()
+	annotationSet := m.compilerFor(module).GetAnnotationSet()
 	if annotationSet == nil {
 		return nil
 	}
@@ -266,7 +296,7 @@ func (m *MetadataRetriever) RetrieveMetadata(ctx context.Context, module *ast.Mo
 
 	options := []func(*rego.Rego){
 		rego.Query(metadataQuery),
-		rego.Compiler(m.compiler),
+		rego.Compiler(m.compilerFor(module)),
 		rego.Capabilities(nil),
 	}
 	// support dynamic metadata fields
@@ -329,7 +359,7 @@ func (m *MetadataRetriever) queryInputOptions(ctx context.Context, module *ast.M
 		inputOptionQuery := fmt.Sprintf("data.%s.__rego_input__", namespace)
 		instance := rego.New(
 			rego.Query(inputOptionQuery),
-			rego.Compiler(m.compiler),
+			rego.Compiler(m.compilerFor(module)),
 			rego.Capabilities(nil),
 		)
 		set, err := instance.Eval(ctx)
@@ -351,6 +381,17 @@ func (m *MetadataRetriever) queryInputOptions(ctx context.Context, module *ast.M
 		metadata = meta
 	}
 
+	return parseInputOptions(metadata)
+}
+
+// nolint: cyclop
+func parseInputOptions(metadata map[string]interface{}) InputOptions {
+
+	options := InputOptions{
+		Combined:  false,
+		Selectors: nil,
+	}
+
 	if raw, ok := metadata["combine"]; ok {
 		if combine, ok := raw.(bool); ok {
 			options.Combined = combine
@@ -388,6 +429,76 @@ func (m *MetadataRetriever) queryInputOptions(ctx context.Context, module *ast.M
 
 }
 
+// staticMetadata reads the metadata of an uncompiled module from its package annotations, or from constant
+// __rego_metadata__ and __rego_input__ rules. It returns false if the metadata can only be known by evaluating the
+// module, in which case it must be compiled first and read with a MetadataRetriever.
+func staticMetadata(module *ast.Module) (*StaticMetadata, bool) {
+	var annotations *ast.Annotations
+	for _, candidate := range module.Annotations {
+		if candidate.Scope == "package" {
+			annotations = candidate
+			break
+		}
+	}
+
+	var input map[string]interface{}
+	if annotations != nil && annotations.Custom != nil {
+		input, _ = annotations.Custom["input"].(map[string]interface{})
+	}
+	if input == nil {
+		var ok bool
+		if input, ok = constantRuleObject(module, "__rego_input__"); !ok {
+			return nil, false
+		}
+	}
+	metadata := NewStaticMetadata(module.Package.Path.String(), parseInputOptions(input))
+
+	if annotations != nil {
+		if err := metadata.FromAnnotations(annotations); err != nil {
+			return nil, false
+		}
+		return metadata, true
+	}
+	meta, ok := constantRuleObject(module, "__rego_metadata__")
+	if !ok {
+		return nil, false
+	}
+	if meta != nil {
+		if err := metadata.Update(meta); err != nil {
+			return nil, false
+		}
+	}
+	return metadata, true
+}
+
+// constantRuleObject returns the value of the named rule if it is defined once, unconditionally, as a constant object.
+// It returns nil if the module does not define the rule, and false if the rule is defined in any other way.
+func constantRuleObject(module *ast.Module, name string) (map[string]interface{}, bool) {
+	var found *ast.Rule
+	for _, rule := range module.Rules {
+		if rule.Head.Name.String() != name {
+			continue
+		}
+		if found != nil {
+			return nil, false
+		}
+		found = rule
+	}
+	if found == nil {
+		return nil, true
+	}
+	if found.Else != nil || found.Head.Value == nil || !found.Head.Value.IsGround() || len(found.Head.Args) > 0 ||
+		!found.Body.Equal(ast.NewBody(ast.NewExpr(ast.BooleanTerm(true)))) {
+		return nil, false
+	}
+	value, err := ast.JSON(found.Head.Value.Value)
+	if err != nil {
+		return nil, false
+	}
+	object, ok := value.(map[string]interface{})
+	return object, ok
+}
+
 func getModuleNamespace(module *ast.Module) string {
 	return strings.TrimPrefix(module.Package.Path.String(), "data.")
 }
@@ -77,6 +77,26 @@ func Test_UpdateStaticMetadata(t *testing.T) {
 		assert.Equal(t, expected, sm)
 	})
 
+	t.Run("frameworks decoded from annotations", func(t *testing.T) {
+		sm := StaticMetadata{
+			Frameworks: make(map[framework.Framework][]string),
+		}
+		require.NoError(t, sm.Update(map[string]any{
+			"frameworks": map[string]any{
+				"cis-aws-1.4": []any{"2.1", "2.2"},
+			},
+		}))
+		assert.Equal(t, map[framework.Framework][]string{
+			framework.CIS_AWS_1_4: {"2.1", "2.2"},
+		}, sm.Frameworks)
+
+		assert.Error(t, sm.Update(map[string]any{
+			"frameworks": map[string]any{
+				"cis-aws-1.4": "2.1",
+			},
+		}))
+	})
+
 	t.Run("related resources are a map", func(t *testing.T) {
 		sm := StaticMetadata{
 			References: []string{"r"},
@@ -0,0 +1,162 @@
+package rego
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/open-policy-agent/opa/ast"
+)
+
+// PolicySource is where a loaded module came from
+type PolicySource string
+
+const (
+	PolicySourceEmbedded PolicySource = "embedded"
+	PolicySourceBundle   PolicySource = "bundle"
+	PolicySourceDir      PolicySource = "dir"
+	PolicySourceReader   PolicySource = "reader"
+)
+
+// DefaultPolicyPrecedence lists policy sources from highest to lowest precedence: a policy read from a reader shadows
+// one from a directory with the same AVD ID or package, which in turn shadows a bundled or embedded policy.
+func DefaultPolicyPrecedence() []PolicySource {
+	return []PolicySource{PolicySourceReader, PolicySourceDir, PolicySourceBundle, PolicySourceEmbedded}
+}
+
+// addPolicy adds a module to the policies to be compiled, unless a module with the same filename has already been
+// loaded from a source with higher precedence
+func (s *Scanner) addPolicy(filename string, module *ast.Module, source PolicySource) {
+	if s.sources == nil {
+		s.sources = make(map[string]PolicySource)
+	}
+	if existing, ok := s.sources[filename]; ok && s.precedenceOf(existing) > s.precedenceOf(source) {
+		s.debug.Log("Policy %s from %s is shadowed by the %s policy with the same filename.", filename, source, existing)
+		return
+	}
+	s.policies[filename] = module
+	s.sources[filename] = source
+}
+
+// precedenceOf ranks a source by the configured precedence, where higher ranks win. Sources missing from the
+// precedence all share the lowest rank.
+func (s *Scanner) precedenceOf(source PolicySource) int {
+	return precedenceRank(s.precedence, source)
+}
+
+func precedenceRank(precedence []PolicySource, source PolicySource) int {
+	for i, candidate := range precedence {
+		if candidate == source {
+			return len(precedence) - i
+		}
+	}
+	return 0
+}
+
+type checkIdentity struct {
+	filename string
+	avdID    string
+	pkg      string
+	rank     int
+}
+
+// shadowPolicies removes every check which shares an AVD ID or package path with a check from a source with higher
+// precedence, so that a check overridden locally is neither compiled nor reported twice. Modules without enforced rules
+// or an AVD ID, such as libraries and exceptions, are never shadowed. A diagnostic is recorded for each removed module.
+func (s *Scanner) shadowPolicies() {
+	var checks []checkIdentity
+	highestByID := make(map[string]checkIdentity)
+	highestByPackage := make(map[string]checkIdentity)
+
+	filenames := make([]string, 0, len(s.policies))
+	for filename := range s.policies {
+		filenames = append(filenames, filename)
+	}
+	sort.Strings(filenames)
+
+	for _, filename := range filenames {
+		module := s.policies[filename]
+		check := checkIdentity{
+			filename: filename,
+			avdID:    staticAVDID(module),
+			pkg:      module.Package.Path.String(),
+			rank:     s.precedenceOf(s.sources[filename]),
+		}
+		if check.avdID == "" && !hasEnforcedRules(module) {
+			continue
+		}
+		checks = append(checks, check)
+		if check.avdID != "" {
+			if highest, ok := highestByID[check.avdID]; !ok || check.rank > highest.rank {
+				highestByID[check.avdID] = check
+			}
+		}
+		if highest, ok := highestByPackage[check.pkg]; !ok || check.rank > highest.rank {
+			highestByPackage[check.pkg] = check
+		}
+	}
+
+	for _, check := range checks {
+		var by checkIdentity
+		var reason string
+		if highest, ok := highestByID[check.avdID]; ok && check.avdID != "" && highest.rank > check.rank {
+			by, reason = highest, fmt.Sprintf("avd_id %s", check.avdID)
+		} else if highest := highestByPackage[check.pkg]; highest.rank > check.rank {
+			by, reason = highest, fmt.Sprintf("package %s", check.pkg)
+		} else {
+			continue
+		}
+
+		message := fmt.Sprintf("%s policy is shadowed by %s policy %s with the same %s",
+			s.sources[check.filename], s.sources[by.filename], by.filename, reason)
+		s.debug.Log("%s: %s", check.filename, message)
+		diagnostic := Diagnostic{
+			Filename: check.filename,
+			Code:     "rego_shadowed_policy",
+			Message:  message,
+			Pruned:   true,
+		}
+		if location := s.policies[check.filename].Package.Location; location != nil {
+			diagnostic.Row = location.Row
+			diagnostic.Col = location.Col
+		}
+		s.diagnostics = append(s.diagnostics, diagnostic)
+		delete(s.policies, check.filename)
+	}
+}
+
+// staticAVDID reads the AVD ID of an uncompiled module from its package annotations, or from a constant
+// __rego_metadata__ rule
+func staticAVDID(module *ast.Module) string {
+	for _, annotations := range module.Annotations {
+		if annotations.Scope != "package" || annotations.Custom == nil {
+			continue
+		}
+		if id, ok := annotations.Custom["avd_id"].(string); ok {
+			return id
+		}
+	}
+	for _, rule := range module.Rules {
+		if rule.Head.Name.String() != "__rego_metadata__" || rule.Head.Value == nil {
+			continue
+		}
+		object, ok := rule.Head.Value.Value.(ast.Object)
+		if !ok {
+			continue
+		}
+		if term := object.Get(ast.StringTerm("avd_id")); term != nil {
+			if id, ok := term.Value.(ast.String); ok {
+				return string(id)
+			}
+		}
+	}
+	return ""
+}
+
+func hasEnforcedRules(module *ast.Module) bool {
+	for _, rule := range module.Rules {
+		if isEnforcedRule(rule.Head.Name.String()) {
+			return true
+		}
+	}
+	return false
+}
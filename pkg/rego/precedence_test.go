@@ -0,0 +1,191 @@
+package rego
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/khulnasoft-lab/misscan/pkg/scan"
+	"github.com/khulnasoft-lab/misscan/pkg/scanners/options"
+	"github.com/khulnasoft-lab/misscan/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_RegoScanning_PolicyPrecedence(t *testing.T) {
+
+	srcFS := CreateFS(t, map[string]string{
+		"policies/ds024.rego": `
+# METADATA
+# title: "'apt-get dist-upgrade' used"
+# custom:
+#   avd_id: AVD-DS-0024
+#   input:
+#     selector:
+#     - type: dockerfile
+package builtin.dockerfile.DS024
+
+deny[res] {
+    contains(input.Stages[_].Commands[_].Value[0], "dist-upgrade")
+    res := "local"
+}
+`,
+	})
+
+	input := Input{
+		Path: "Dockerfile",
+		Contents: map[string]interface{}{
+			"Stages": []interface{}{
+				map[string]interface{}{
+					"Name": "alpine",
+					"Commands": []interface{}{
+						map[string]interface{}{
+							"Cmd":       "run",
+							"Value":     []interface{}{"apt-get update && apt-get dist-upgrade"},
+							"StartLine": 2,
+							"EndLine":   2,
+						},
+					},
+				},
+			},
+		},
+	}
+
+	t.Run("directory shadows embedded", func(t *testing.T) {
+		scanner := NewScanner(types.SourceDockerfile)
+		require.NoError(
+			t,
+			scanner.LoadPolicies(true, true, srcFS, []string{"policies"}, nil),
+		)
+
+		results, err := scanner.ScanInput(context.TODO(), input)
+		require.NoError(t, err)
+		var failed []scan.Result
+		for _, result := range results.GetFailed() {
+			if result.Rule().AVDID == "AVD-DS-0024" {
+				failed = append(failed, result)
+			}
+		}
+		require.Len(t, failed, 1)
+		assert.Equal(t, "local", failed[0].Description())
+
+		var shadowed []Diagnostic
+		for _, diagnostic := range scanner.Diagnostics() {
+			if diagnostic.Code == "rego_shadowed_policy" {
+				shadowed = append(shadowed, diagnostic)
+			}
+		}
+		require.Len(t, shadowed, 1)
+		assert.NotEqual(t, "policies/ds024.rego", shadowed[0].Filename)
+		assert.Contains(t, shadowed[0].Message, "embedded policy is shadowed by dir policy policies/ds024.rego")
+		assert.True(t, shadowed[0].Pruned)
+	})
+
+	t.Run("embedded shadows directory", func(t *testing.T) {
+		scanner := NewScanner(types.SourceDockerfile, options.ScannerWithPolicyPrecedence("embedded", "dir"))
+		require.NoError(
+			t,
+			scanner.LoadPolicies(true, true, srcFS, []string{"policies"}, nil),
+		)
+		assert.NotContains(t, scanner.policies, "policies/ds024.rego")
+
+		results, err := scanner.ScanInput(context.TODO(), input)
+		require.NoError(t, err)
+		var failed []scan.Result
+		for _, result := range results.GetFailed() {
+			if result.Rule().AVDID == "AVD-DS-0024" {
+				failed = append(failed, result)
+			}
+		}
+		require.Len(t, failed, 1)
+		assert.NotEqual(t, "local", failed[0].Description())
+	})
+}
+
+func Test_RegoScanning_PolicyPrecedenceByAVDID(t *testing.T) {
+
+	srcFS := CreateFS(t, map[string]string{
+		"policies/old.rego": `
+# METADATA
+# custom:
+#   avd_id: AVD-TEST-0001
+package misscan.old
+
+deny {
+    input.evil
+}
+`,
+		"policies/old_exceptions.rego": `
+package misscan.old
+
+exception[rules] {
+    rules := ["something"]
+}
+`,
+		"policies/other.rego": `
+package misscan.other
+
+deny {
+    input.evil
+}
+`,
+	})
+
+	reader := func() []io.Reader {
+		return []io.Reader{strings.NewReader(`
+package misscan.fixed
+
+__rego_metadata__ := {
+    "avd_id": "AVD-TEST-0001",
+}
+
+deny {
+    input.evil
+}
+`)}
+	}
+
+	t.Run("default precedence", func(t *testing.T) {
+		scanner := NewScanner(types.SourceJSON)
+		require.NoError(
+			t,
+			scanner.LoadPolicies(false, false, srcFS, []string{"policies"}, reader()),
+		)
+		assert.NotContains(t, scanner.policies, "policies/old.rego")
+		assert.Contains(t, scanner.policies, "policies/old_exceptions.rego")
+		assert.Contains(t, scanner.policies, "policies/other.rego")
+
+		diagnostics := scanner.Diagnostics()
+		require.Len(t, diagnostics, 1)
+		assert.Equal(t, Diagnostic{
+			Filename: "policies/old.rego",
+			Row:      5,
+			Col:      1,
+			Code:     "rego_shadowed_policy",
+			Message:  "dir policy is shadowed by reader policy reader_0 with the same avd_id AVD-TEST-0001",
+			Pruned:   true,
+		}, diagnostics[0])
+
+		results, err := scanner.ScanInput(context.TODO(), Input{
+			Path:     "/evil.json",
+			Contents: map[string]interface{}{"evil": true},
+		})
+		require.NoError(t, err)
+		var namespaces []string
+		for _, result := range results.GetFailed() {
+			namespaces = append(namespaces, result.RegoNamespace())
+		}
+		assert.ElementsMatch(t, []string{"misscan.fixed", "misscan.other"}, namespaces)
+	})
+
+	t.Run("no precedence", func(t *testing.T) {
+		scanner := NewScanner(types.SourceJSON, options.ScannerWithPolicyPrecedence())
+		require.NoError(
+			t,
+			scanner.LoadPolicies(false, false, srcFS, []string{"policies"}, reader()),
+		)
+		assert.Contains(t, scanner.policies, "policies/old.rego")
+		assert.Empty(t, scanner.Diagnostics())
+	})
+}
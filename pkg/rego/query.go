@@ -0,0 +1,131 @@
+package rego
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/open-policy-agent/opa/rego"
+	"github.com/open-policy-agent/opa/resolver/wasm"
+)
+
+// queryCache holds queries prepared against the current compiler, so that each query is only compiled once per
+// policy load rather than once per evaluation
+type queryCache struct {
+	sync.RWMutex
+	queries  map[string]rego.PreparedEvalQuery
+	wasm     map[string]rego.PreparedEvalQuery // rule queries resolved by the Wasm module
+	resolver *wasm.Resolver                    // evaluates the entrypoints of the Wasm module
+	engines  map[string]PolicyEngine
+}
+
+func newQueryCache() *queryCache {
+	return &queryCache{
+		queries: make(map[string]rego.PreparedEvalQuery),
+		wasm:    make(map[string]rego.PreparedEvalQuery),
+		engines: make(map[string]PolicyEngine),
+	}
+}
+
+func ruleQuery(namespace string, rule string) string {
+	return fmt.Sprintf("data.%s.%s", namespace, rule)
+}
+
+func namespaceExceptionQuery(namespace string) string {
+	return fmt.Sprintf("data.namespace.exceptions.exception[_] == %q", namespace)
+}
+
+func ruleExceptionQuery(namespace string, rule string) string {
+	return fmt.Sprintf("endswith(%q, data.%s.exception[_][_])", rule, namespace)
+}
+
+// prepareQueries prepares the enforced rule queries and their exception queries for every loaded policy, other than
+// those kept from a previous compile
+func (s *Scanner) prepareQueries(ctx context.Context) error {
+	prepare := func(query string) error {
+		if _, ok := s.queries.get(query); ok {
+			return nil
+		}
+		_, err := s.prepareQuery(ctx, s.compiler, query)
+		return err
+	}
+
+	policies := make(map[string][]string)
+	for _, module := range s.policies {
+		namespace := getModuleNamespace(module)
+		topLevel := strings.Split(namespace, ".")[0]
+		if _, ok := s.ruleNamespaces[topLevel]; !ok {
+			continue
+		}
+		if err := prepare(namespaceExceptionQuery(namespace)); err != nil {
+			return fmt.Errorf("prepare namespace exception query for %s: %w", namespace, err)
+		}
+		for _, rule := range module.Rules {
+			ruleName := rule.Head.Name.String()
+			if !isEnforcedRule(ruleName) {
+				continue
+			}
+			if !slices.Contains(policies[namespace], ruleName) {
+				policies[namespace] = append(policies[namespace], ruleName)
+			}
+			for _, query := range []string{ruleQuery(namespace, ruleName), ruleExceptionQuery(namespace, ruleName)} {
+				if err := prepare(query); err != nil {
+					return fmt.Errorf("prepare query %s: %w", query, err)
+				}
+			}
+		}
+	}
+	s.prepareWasmQueries(ctx, policies)
+	s.debug.Log("Prepared %d queries.", len(s.queries.queries))
+	return nil
+}
+
+func (c *queryCache) get(query string) (rego.PreparedEvalQuery, bool) {
+	c.RLock()
+	defer c.RUnlock()
+	prepared, ok := c.queries[query]
+	return prepared, ok
+}
+
+// preparedQuery returns the cached prepared query, preparing it first against every loaded policy if it has not been
+// seen before
+func (s *Scanner) preparedQuery(ctx context.Context, query string) (rego.PreparedEvalQuery, error) {
+	if s.queries == nil {
+		return rego.PreparedEvalQuery{}, fmt.Errorf("policies have not been compiled")
+	}
+	if prepared, ok := s.queries.get(query); ok {
+		return prepared, nil
+	}
+	compiler, err := s.completeCompiler()
+	if err != nil {
+		return rego.PreparedEvalQuery{}, err
+	}
+	return s.prepareQuery(ctx, compiler, query)
+}
+
+// prepareQuery prepares the query against the compiler and caches it
+func (s *Scanner) prepareQuery(ctx context.Context, compiler *ast.Compiler, query string) (rego.PreparedEvalQuery, error) {
+	regoOptions := []func(*rego.Rego){
+		rego.Query(query),
+		rego.Compiler(compiler),
+		rego.Store(s.store),
+		rego.Runtime(s.runtimeValues),
+	}
+
+	if s.inputSchema != nil {
+		regoOptions = append(regoOptions, rego.Schemas(s.inputSchemaSet()))
+	}
+
+	prepared, err := rego.New(regoOptions...).PrepareForEval(ctx)
+	if err != nil {
+		return rego.PreparedEvalQuery{}, err
+	}
+
+	s.queries.Lock()
+	s.queries.queries[query] = prepared
+	s.queries.Unlock()
+	return prepared, nil
+}
@@ -0,0 +1,72 @@
+package rego
+
+import (
+	"context"
+	"testing"
+
+	"github.com/khulnasoft-lab/misscan/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_PreparedQueries(t *testing.T) {
+
+	srcFS := CreateFS(t, map[string]string{
+		"policies/test.rego": `
+package misscan.test
+
+deny {
+    input.evil
+}
+
+warn {
+    input.suspicious
+}
+
+helper {
+    true
+}
+`,
+		"policies/lib.rego": `
+package lib.test
+
+deny {
+    input.evil
+}
+`,
+	})
+
+	scanner := NewScanner(types.SourceJSON)
+	require.NoError(
+		t,
+		scanner.LoadPolicies(false, false, srcFS, []string{"policies"}, nil),
+	)
+
+	expected := []string{
+		namespaceExceptionQuery("misscan.test"),
+		ruleQuery("misscan.test", "deny"),
+		ruleExceptionQuery("misscan.test", "deny"),
+		ruleQuery("misscan.test", "warn"),
+		ruleExceptionQuery("misscan.test", "warn"),
+	}
+	require.Len(t, scanner.queries.queries, len(expected))
+	for _, query := range expected {
+		assert.Contains(t, scanner.queries.queries, query)
+	}
+
+	for i := 0; i < 2; i++ {
+		results, err := scanner.ScanInput(context.TODO(), Input{
+			Path: "/evil.lol",
+			Contents: map[string]interface{}{
+				"evil": true,
+			},
+		})
+		require.NoError(t, err)
+		assert.Len(t, results.GetFailed(), 1)
+		assert.Len(t, results.GetPassed(), 1)
+	}
+	assert.Len(t, scanner.queries.queries, len(expected))
+
+	require.NoError(t, scanner.compilePolicies(srcFS, []string{"policies"}, nil))
+	assert.Empty(t, scanner.queries.queries)
+}
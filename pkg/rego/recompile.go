@@ -0,0 +1,260 @@
+package rego
+
+import (
+	"context"
+	"io/fs"
+	"sync"
+
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/open-policy-agent/opa/storage"
+)
+
+// exceptionsNamespace is the package of the namespace exceptions, which every namespace exception query refers to
+const exceptionsNamespace = "namespace.exceptions"
+
+// previousCompile is the state of the scanner before a reload, from which only the packages affected by the modules
+// which changed since have to be compiled again
+type previousCompile struct {
+	compiled    map[string]*ast.Module // the modules passed to the compiler
+	policies    map[string]*ast.Module // the policies which remained after the modules were compiled and filtered
+	inputSchema interface{}
+	store       storage.Store
+	retriever   *MetadataRetriever
+	queries     *queryCache
+}
+
+// hasNamespaces returns true if the namespaces stored as data.namespaces are the given namespaces, in which case the
+// store can be kept
+func (p *previousCompile) hasNamespaces(ctx context.Context, namespaces []string) bool {
+	stored, err := storage.ReadOne(ctx, p.store, storage.Path{"namespaces"})
+	if err != nil {
+		return false
+	}
+	value, err := ast.InterfaceToValue(stored)
+	if err != nil {
+		return false
+	}
+	array, ok := value.(*ast.Array)
+	if !ok {
+		return false
+	}
+	previous := ast.NewSet()
+	array.Foreach(func(term *ast.Term) {
+		previous.Add(term)
+	})
+	current := ast.NewSet()
+	for _, namespace := range namespaces {
+		current.Add(ast.StringTerm(namespace))
+	}
+	return previous.Compare(current) == 0
+}
+
+// affectedPackages returns the packages of the modules which were added, changed or removed since the previous
+// compile, and every package which depends on them before or after the change
+func (p *previousCompile) affectedPackages(modules map[string]*ast.Module) map[string]struct{} {
+	affected := make(map[string]struct{})
+	for filename, module := range modules {
+		previous, ok := p.compiled[filename]
+		if ok && previous == module {
+			continue
+		}
+		affected[getModuleNamespace(module)] = struct{}{}
+		if ok {
+			affected[getModuleNamespace(previous)] = struct{}{}
+		}
+	}
+	for filename, module := range p.compiled {
+		if _, ok := modules[filename]; !ok {
+			affected[getModuleNamespace(module)] = struct{}{}
+		}
+	}
+
+	importedBy := make(map[string][]string)
+	for _, report := range []*DependencyReport{AnalyseDependencies(p.compiled), AnalyseDependencies(modules)} {
+		for _, pkg := range report.Packages {
+			importedBy[pkg.Package] = append(importedBy[pkg.Package], pkg.ImportedBy...)
+		}
+	}
+	queue := make([]string, 0, len(affected))
+	for namespace := range affected {
+		queue = append(queue, namespace)
+	}
+	for len(queue) > 0 {
+		namespace := queue[0]
+		queue = queue[1:]
+		for _, dependent := range importedBy[namespace] {
+			if _, ok := affected[dependent]; !ok {
+				affected[dependent] = struct{}{}
+				queue = append(queue, dependent)
+			}
+		}
+	}
+	return affected
+}
+
+// compilationUnit returns the modules of the affected packages and of every package they depend on, along with the
+// namespace exceptions, which are needed to compile the affected packages on their own
+func compilationUnit(modules map[string]*ast.Module, affected map[string]struct{}) map[string]*ast.Module {
+	imports := make(map[string][]string)
+	for _, pkg := range AnalyseDependencies(modules).Packages {
+		imports[pkg.Package] = pkg.Imports
+	}
+
+	packages := map[string]struct{}{
+		exceptionsNamespace: {},
+	}
+	queue := []string{exceptionsNamespace}
+	for namespace := range affected {
+		packages[namespace] = struct{}{}
+		queue = append(queue, namespace)
+	}
+	for len(queue) > 0 {
+		namespace := queue[0]
+		queue = queue[1:]
+		for _, dependency := range imports[namespace] {
+			if _, ok := packages[dependency]; !ok {
+				packages[dependency] = struct{}{}
+				queue = append(queue, dependency)
+			}
+		}
+	}
+
+	unit := make(map[string]*ast.Module)
+	for filename, module := range modules {
+		if _, ok := packages[getModuleNamespace(module)]; ok {
+			unit[filename] = module
+		}
+	}
+	return unit
+}
+
+// recompilePolicies compiles the packages affected by the modules which changed since the previous compile, together
+// with the packages they depend on. The other modules keep the outcome of the previous compile: they are filtered as
+// they were, their metadata is read from the compiler they were compiled by and their prepared queries are kept.
+func (s *Scanner) recompilePolicies(srcFS fs.FS, paths []string, schemaSet *ast.SchemaSet, previous *previousCompile) error {
+	affected := previous.affectedPackages(s.policies)
+	unit := compilationUnit(s.policies, affected)
+
+	compiler := newCompiler(schemaSet, s.sandbox)
+	compiler.Compile(unit)
+	if compiler.Failed() {
+		if err := s.prunePoliciesWithError(compiler); err != nil {
+			return err
+		}
+		return s.compilePolicies(srcFS, paths, previous)
+	}
+
+	retriever := &MetadataRetriever{
+		compiler:  compiler,
+		compilers: make(map[string]*ast.Compiler),
+	}
+	changed := make(map[string]*ast.Module)
+	policies := make(map[string]*ast.Module)
+	for filename, module := range s.policies {
+		namespace := getModuleNamespace(module)
+		if _, ok := affected[namespace]; ok {
+			changed[filename] = module
+			continue
+		}
+		if _, ok := unit[filename]; !ok {
+			retriever.compilers[namespace] = previous.retriever.compilerFor(module)
+		}
+		if _, ok := previous.policies[filename]; ok {
+			policies[filename] = module
+		}
+	}
+
+	filtered, err := s.filterModules(retriever, changed)
+	if err != nil {
+		return err
+	}
+	for filename, module := range filtered {
+		policies[filename] = module
+	}
+	s.policies = policies
+
+	if s.inputSchema != nil {
+		schemaSet = s.inputSchemaSet()
+		compiled := make(map[string]*ast.Module)
+		for filename, module := range unit {
+			if _, ok := s.policies[filename]; ok {
+				compiled[filename] = module
+			}
+		}
+		compiler.WithSchemas(schemaSet)
+		compiler.Compile(compiled)
+		if compiler.Failed() {
+			if err := s.prunePoliciesWithError(compiler); err != nil {
+				return err
+			}
+			return s.compilePolicies(srcFS, paths, previous)
+		}
+	}
+
+	queries := newQueryCache()
+	previous.queries.RLock()
+	for _, module := range s.policies {
+		namespace := getModuleNamespace(module)
+		if _, ok := affected[namespace]; ok {
+			continue
+		}
+		keep := []string{}
+		if _, ok := affected[exceptionsNamespace]; !ok {
+			keep = append(keep, namespaceExceptionQuery(namespace))
+		}
+		for _, rule := range module.Rules {
+			ruleName := rule.Head.Name.String()
+			keep = append(keep, ruleQuery(namespace, ruleName), ruleExceptionQuery(namespace, ruleName))
+		}
+		for _, query := range keep {
+			if prepared, ok := previous.queries.queries[query]; ok {
+				queries.queries[query] = prepared
+			}
+		}
+	}
+	previous.queries.RUnlock()
+
+	s.debug.Log("Compiled %d of %d modules affected by changes.", len(unit), len(s.compiled))
+	s.compiler = compiler
+	s.deferred = &deferredCompile{
+		modules:   s.policies,
+		schemaSet: schemaSet,
+		sandbox:   s.sandbox,
+	}
+	s.retriever = retriever
+	s.queries = queries
+	return nil
+}
+
+// deferredCompile compiles the whole policy set on first use, for queries which may refer to any package when the
+// policies were only compiled in part
+type deferredCompile struct {
+	modules   map[string]*ast.Module
+	schemaSet *ast.SchemaSet
+	sandbox   Sandbox
+
+	once     sync.Once
+	compiler *ast.Compiler
+	err      error
+}
+
+func (d *deferredCompile) get() (*ast.Compiler, error) {
+	d.once.Do(func() {
+		compiler := newCompiler(d.schemaSet, d.sandbox)
+		compiler.Compile(d.modules)
+		if compiler.Failed() {
+			d.err = compiler.Errors
+			return
+		}
+		d.compiler = compiler
+	})
+	return d.compiler, d.err
+}
+
+// completeCompiler returns a compiler holding every loaded policy
+func (s *Scanner) completeCompiler() (*ast.Compiler, error) {
+	if s.deferred == nil {
+		return s.compiler, nil
+	}
+	return s.deferred.get()
+}
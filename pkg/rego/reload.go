@@ -0,0 +1,351 @@
+package rego
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/open-policy-agent/opa/bundle"
+)
+
+type cachedModule struct {
+	modTime time.Time
+	size    int64
+	module  *ast.Module
+}
+
+type cachedBundle struct {
+	modTime time.Time
+	size    int64
+	bundle  *bundle.Bundle
+}
+
+// moduleCache holds parsed modules keyed by path, so that only added or changed files are parsed again on reload.
+// Embedded modules and bundles are cached too, so that a reload can tell which modules have changed by comparing them.
+type moduleCache struct {
+	mu       sync.Mutex
+	modules  map[string]cachedModule
+	bundles  map[string]cachedBundle
+	embedded map[string]map[string]*ast.Module
+}
+
+func newModuleCache() *moduleCache {
+	return &moduleCache{
+		modules:  make(map[string]cachedModule),
+		bundles:  make(map[string]cachedBundle),
+		embedded: make(map[string]map[string]*ast.Module),
+	}
+}
+
+func (c *moduleCache) get(path string, info fs.FileInfo) (*ast.Module, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cached, ok := c.modules[path]
+	if !ok || !cached.modTime.Equal(info.ModTime()) || cached.size != info.Size() {
+		return nil, false
+	}
+	return cached.module, true
+}
+
+func (c *moduleCache) put(path string, info fs.FileInfo, module *ast.Module) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.modules[path] = cachedModule{
+		modTime: info.ModTime(),
+		size:    info.Size(),
+		module:  module,
+	}
+}
+
+func (c *moduleCache) getBundle(path string, info fs.FileInfo) (*bundle.Bundle, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cached, ok := c.bundles[path]
+	if !ok || !cached.modTime.Equal(info.ModTime()) || cached.size != info.Size() {
+		return nil, false
+	}
+	return cached.bundle, true
+}
+
+func (c *moduleCache) putBundle(path string, info fs.FileInfo, b *bundle.Bundle) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.bundles[path] = cachedBundle{
+		modTime: info.ModTime(),
+		size:    info.Size(),
+		bundle:  b,
+	}
+}
+
+// loadEmbedded loads the embedded modules with the given name once, as they cannot change
+func (c *moduleCache) loadEmbedded(name string, load func() (map[string]*ast.Module, error)) (map[string]*ast.Module, error) {
+	if c == nil {
+		return load()
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if modules, ok := c.embedded[name]; ok {
+		return modules, nil
+	}
+	modules, err := load()
+	if err != nil {
+		return nil, err
+	}
+	c.embedded[name] = modules
+	return modules, nil
+}
+
+// loadArgs records the arguments of the last LoadPolicies call so that policies can be reloaded
+type loadArgs struct {
+	embeddedLibraries bool
+	embeddedPolicies  bool
+	srcFS             fs.FS
+	paths             []string
+	readerModules     map[string]*ast.Module // readers can only be consumed once, so the parsed modules are kept
+	inputSchema       interface{}
+	files             map[string]fileState // the policy and data files present when the policies were loaded
+}
+
+// reloadState is shared between a scanner and the copies it loads replacement policies into
+type reloadState struct {
+	// mu is held for reading while a scan is running, and for writing while reloaded policies are swapped in
+	mu      sync.RWMutex
+	modules *moduleCache
+
+	argsMu sync.Mutex
+	args   *loadArgs
+}
+
+func newReloadState() *reloadState {
+	return &reloadState{
+		modules: newModuleCache(),
+	}
+}
+
+func (r *reloadState) record(args loadArgs) {
+	r.argsMu.Lock()
+	defer r.argsMu.Unlock()
+	r.args = &args
+}
+
+func (r *reloadState) lastArgs() *loadArgs {
+	r.argsMu.Lock()
+	defer r.argsMu.Unlock()
+	return r.args
+}
+
+// Reload loads policies, data and exceptions again using the arguments of the last LoadPolicies call. Only modules
+// whose files have changed are parsed again. If nothing but policy modules and the exceptions file changed since the
+// last load, only the packages of the changed modules and the packages which depend on them are compiled again, and the
+// data and the queries of the other packages are kept; otherwise the policy set is compiled as a whole. The new policies
+// are swapped in atomically once they have compiled: scans already running finish with the old policies, and if
+// reloading fails the current policies remain in use.
+func (s *Scanner) Reload(ctx context.Context) error {
+	args := s.reload.lastArgs()
+	if args == nil {
+		return fmt.Errorf("policies have not been loaded")
+	}
+
+	files, err := s.snapshot(args)
+	if err != nil {
+		s.debug.Log("Failed to check which policy and data files changed, compiling all policies: %s", err)
+	}
+
+	s.reload.mu.RLock()
+	next := *s
+	current := s.queries
+	var previous *previousCompile
+	if err == nil && s.canRecompile(args.files, files) {
+		previous = &previousCompile{
+			compiled:    s.compiled,
+			policies:    s.policies,
+			inputSchema: s.inputSchema,
+			store:       s.store,
+			retriever:   s.retriever,
+			queries:     s.queries,
+		}
+	}
+	s.reload.mu.RUnlock()
+
+	next.inputSchema = args.inputSchema
+	next.policies = nil
+	next.sources = nil
+	if err := next.loadPolicies(ctx, *args, previous); err != nil {
+		if next.queries != current {
+			next.queries.close()
+		}
+		return err
+	}
+
+	s.reload.mu.Lock()
+	defer s.reload.mu.Unlock()
+	// no scan is running while the lock is held, so the queries being replaced are no longer in use
+	if s.queries != nil && s.queries != next.queries {
+		defer s.queries.close()
+	}
+	s.policies = next.policies
+	s.sources = next.sources
+	s.store = next.store
+	s.compiler = next.compiler
+	s.compiled = next.compiled
+	s.deferred = next.deferred
+	s.retriever = next.retriever
+	s.queries = next.queries
+	s.inputSchema = next.inputSchema
+	s.diagnostics = next.diagnostics
+	s.exceptions = next.exceptions
+	s.debug.Log("Reloaded %d policies.", len(s.policies))
+
+	reloaded := *args
+	reloaded.files = files
+	s.reload.record(reloaded)
+	return nil
+}
+
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+// snapshot records the modification time and size of every file under the policy and data directories, of bundles and
+// of the exceptions file
+func (s *Scanner) snapshot(args *loadArgs) (map[string]fileState, error) {
+	files := make(map[string]fileState)
+	walk := func(fsys fs.FS, prefix string, paths []string) error {
+		for _, root := range paths {
+			err := fs.WalkDir(fsys, sanitisePath(root), func(path string, entry fs.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+				if entry.IsDir() {
+					return nil
+				}
+				info, err := entry.Info()
+				if err != nil {
+					return err
+				}
+				files[prefix+path] = fileState{modTime: info.ModTime(), size: info.Size()}
+				return nil
+			})
+			if err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err := walk(args.srcFS, "policy:", append(args.paths, s.policyBundles...)); err != nil {
+		return nil, err
+	}
+	dataFS := args.srcFS
+	if s.dataFS != nil {
+		dataFS = s.dataFS
+	}
+	if err := walk(dataFS, "data:", s.dataDirs); err != nil {
+		return nil, err
+	}
+	if s.exceptionsFile != "" {
+		info, err := os.Stat(s.exceptionsFile)
+		if err == nil {
+			files["exceptions:"+s.exceptionsFile] = fileState{modTime: info.ModTime(), size: info.Size()}
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// canRecompile returns true if the only files which changed since the policies were loaded are policy modules and the
+// exceptions file, in which case only the packages affected by the changed modules have to be compiled again. Wasm
+// modules are always built from the whole policy set.
+func (s *Scanner) canRecompile(previous, current map[string]fileState) bool {
+	if s.wasm || s.compiled == nil || previous == nil {
+		return false
+	}
+	recompilable := func(path string) bool {
+		return strings.HasPrefix(path, "exceptions:") || (strings.HasPrefix(path, "policy:") && IsRegoFile(path))
+	}
+	for path, state := range current {
+		if prev, ok := previous[path]; (!ok || !prev.modTime.Equal(state.modTime) || prev.size != state.size) && !recompilable(path) {
+			return false
+		}
+	}
+	for path := range previous {
+		if _, ok := current[path]; !ok && !recompilable(path) {
+			return false
+		}
+	}
+	return true
+}
+
+func changed(previous, current map[string]fileState) bool {
+	if len(previous) != len(current) {
+		return true
+	}
+	for path, state := range current {
+		if prev, ok := previous[path]; !ok || !prev.modTime.Equal(state.modTime) || prev.size != state.size {
+			return true
+		}
+	}
+	return false
+}
+
+// Watch checks the policy and data directories and the exceptions file from the last LoadPolicies call every interval,
+// and reloads the policies as Reload does when files have been added, changed or removed since they were loaded. The
+// result of every reload attempt is passed to onReload, which may be nil; failed reloads leave the current policies in
+// use. Watch blocks until ctx is done.
+func (s *Scanner) Watch(ctx context.Context, interval time.Duration, onReload func(error)) error {
+	args := s.reload.lastArgs()
+	if args == nil {
+		return fmt.Errorf("policies have not been loaded")
+	}
+
+	previous := args.files
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		current, err := s.snapshot(args)
+		if err != nil {
+			s.debug.Log("Failed to check policies for changes: %s", err)
+			continue
+		}
+		if !changed(previous, current) {
+			continue
+		}
+		previous = current
+
+		s.debug.Log("Policies or data changed, reloading...")
+		err = s.Reload(ctx)
+		if err != nil {
+			s.debug.Log("Failed to reload policies, continuing with the current policies: %s", err)
+		}
+		if onReload != nil {
+			onReload(err)
+		}
+	}
+}
@@ -0,0 +1,268 @@
+package rego
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/khulnasoft-lab/misscan/pkg/scanners/options"
+	"github.com/khulnasoft-lab/misscan/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, dir, name, content string) {
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o700))
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+}
+
+func countFailed(t *testing.T, scanner *Scanner, contents map[string]interface{}) int {
+	results, err := scanner.ScanInput(context.TODO(), Input{
+		Path:     "/input.json",
+		Contents: contents,
+	})
+	require.NoError(t, err)
+	return len(results.GetFailed())
+}
+
+func Test_RegoScanning_Reload(t *testing.T) {
+
+	dir := t.TempDir()
+	writeFile(t, dir, "policies/test.rego", `
+package misscan.test
+
+deny {
+    input.value == data.settings.value
+}
+`)
+	writeFile(t, dir, "data/settings.json", `{"settings": {"value": "evil"}}`)
+
+	scanner := NewScanner(types.SourceJSON, options.ScannerWithDataDirs("data"))
+	require.NoError(
+		t,
+		scanner.LoadPolicies(false, false, os.DirFS(dir), []string{"policies"}, nil),
+	)
+	assert.Equal(t, 1, countFailed(t, scanner, map[string]interface{}{"value": "evil"}))
+
+	t.Run("data changed", func(t *testing.T) {
+		writeFile(t, dir, "data/settings.json", `{"settings": {"value": "bad"}}`)
+		require.NoError(t, scanner.Reload(context.TODO()))
+		assert.Equal(t, 0, countFailed(t, scanner, map[string]interface{}{"value": "evil"}))
+		assert.Equal(t, 1, countFailed(t, scanner, map[string]interface{}{"value": "bad"}))
+	})
+
+	t.Run("policy changed", func(t *testing.T) {
+		writeFile(t, dir, "policies/test.rego", `
+package misscan.test
+
+deny {
+    input.value == "worse"
+}
+`)
+		require.NoError(t, scanner.Reload(context.TODO()))
+		assert.Equal(t, 0, countFailed(t, scanner, map[string]interface{}{"value": "bad"}))
+		assert.Equal(t, 1, countFailed(t, scanner, map[string]interface{}{"value": "worse"}))
+	})
+
+	t.Run("broken policy keeps the current policies", func(t *testing.T) {
+		writeFile(t, dir, "policies/test.rego", `
+package misscan.test
+
+deny {
+`)
+		require.Error(t, scanner.Reload(context.TODO()))
+		assert.Equal(t, 1, countFailed(t, scanner, map[string]interface{}{"value": "worse"}))
+	})
+}
+
+func Test_RegoScanning_ReloadRecompilesAffectedModules(t *testing.T) {
+
+	dir := t.TempDir()
+	writeFile(t, dir, "policies/lib/util.rego", `
+package lib.util
+
+is_evil(value) {
+    value == "evil"
+}
+`)
+	writeFile(t, dir, "policies/uses_lib.rego", `
+package misscan.uses_lib
+
+import data.lib.util
+
+deny {
+    util.is_evil(input.value)
+}
+`)
+	writeFile(t, dir, "policies/other.rego", `
+package misscan.other
+
+deny {
+    input.value == data.settings.value
+}
+`)
+	writeFile(t, dir, "data/settings.json", `{"settings": {"value": "other"}}`)
+
+	scanner := NewScanner(types.SourceJSON, options.ScannerWithDataDirs("data"))
+	require.NoError(
+		t,
+		scanner.LoadPolicies(false, false, os.DirFS(dir), []string{"policies"}, nil),
+	)
+	require.Len(t, scanner.compiler.Modules, 3)
+	assert.Equal(t, 1, countFailed(t, scanner, map[string]interface{}{"value": "evil"}))
+	assert.Equal(t, 1, countFailed(t, scanner, map[string]interface{}{"value": "other"}))
+
+	t.Run("check changed", func(t *testing.T) {
+		previous := scanner.compiler
+		writeFile(t, dir, "policies/other.rego", `
+package misscan.other
+
+deny {
+    input.value == "another"
+}
+`)
+		require.NoError(t, scanner.Reload(context.TODO()))
+		assert.Len(t, scanner.compiler.Modules, 1)
+		assert.Same(t, previous, scanner.retriever.compilerFor(scanner.policies["policies/uses_lib.rego"]))
+
+		assert.Equal(t, 1, countFailed(t, scanner, map[string]interface{}{"value": "evil"}))
+		assert.Equal(t, 0, countFailed(t, scanner, map[string]interface{}{"value": "other"}))
+		assert.Equal(t, 1, countFailed(t, scanner, map[string]interface{}{"value": "another"}))
+	})
+
+	t.Run("library changed", func(t *testing.T) {
+		writeFile(t, dir, "policies/lib/util.rego", `
+package lib.util
+
+is_evil(value) {
+    value == "wicked"
+}
+`)
+		require.NoError(t, scanner.Reload(context.TODO()))
+		assert.Len(t, scanner.compiler.Modules, 2, "the library and the check which depends on it are compiled")
+
+		assert.Equal(t, 0, countFailed(t, scanner, map[string]interface{}{"value": "evil"}))
+		assert.Equal(t, 1, countFailed(t, scanner, map[string]interface{}{"value": "wicked"}))
+		assert.Equal(t, 1, countFailed(t, scanner, map[string]interface{}{"value": "another"}))
+
+		// ad-hoc queries may refer to any package, not only those which were compiled again
+		set, err := scanner.Query(context.TODO(), "data.misscan.other.deny", map[string]interface{}{"value": "another"})
+		require.NoError(t, err)
+		require.Len(t, set, 1)
+		assert.Equal(t, true, set[0].Expressions[0].Value)
+	})
+
+	t.Run("data changed", func(t *testing.T) {
+		writeFile(t, dir, "policies/other.rego", `
+package misscan.other
+
+deny {
+    input.value == data.settings.value
+}
+`)
+		writeFile(t, dir, "data/settings.json", `{"settings": {"value": "changed"}}`)
+		require.NoError(t, scanner.Reload(context.TODO()))
+		assert.Len(t, scanner.compiler.Modules, 3, "all policies are compiled when data changes")
+		assert.Nil(t, scanner.deferred)
+
+		assert.Equal(t, 1, countFailed(t, scanner, map[string]interface{}{"value": "changed"}))
+		assert.Equal(t, 1, countFailed(t, scanner, map[string]interface{}{"value": "wicked"}))
+	})
+}
+
+func Test_RegoScanning_Watch(t *testing.T) {
+
+	dir := t.TempDir()
+	writeFile(t, dir, "policies/test.rego", `
+package misscan.test
+
+deny {
+    input.evil
+}
+`)
+
+	scanner := NewScanner(types.SourceJSON)
+	require.NoError(
+		t,
+		scanner.LoadPolicies(false, false, os.DirFS(dir), []string{"policies"}, nil),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	reloaded := make(chan error, 10)
+	go func() {
+		_ = scanner.Watch(ctx, 10*time.Millisecond, func(err error) {
+			reloaded <- err
+		})
+	}()
+
+	// scans keep running while the policies are being watched
+	assert.Equal(t, 1, countFailed(t, scanner, map[string]interface{}{"evil": true}))
+
+	writeFile(t, dir, "policies/other.rego", `
+package misscan.other
+
+deny {
+    input.wicked
+}
+`)
+
+	select {
+	case err := <-reloaded:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("policies were not reloaded")
+	}
+
+	assert.Equal(t, 1, countFailed(t, scanner, map[string]interface{}{"evil": true}))
+	assert.Equal(t, 1, countFailed(t, scanner, map[string]interface{}{"wicked": true}))
+}
+
+func Test_RegoScanning_WatchExceptionsFile(t *testing.T) {
+
+	dir := t.TempDir()
+	writeFile(t, dir, "policies/test.rego", `
+package misscan.test
+
+deny {
+    input.evil
+}
+`)
+	exceptions := filepath.Join(dir, "exceptions.yaml")
+	writeFile(t, dir, "exceptions.yaml", "exceptions: []\n")
+
+	scanner := NewScanner(types.SourceJSON, options.ScannerWithExceptionsFile(exceptions))
+	require.NoError(
+		t,
+		scanner.LoadPolicies(false, false, os.DirFS(dir), []string{"policies"}, nil),
+	)
+	assert.Equal(t, 1, countFailed(t, scanner, map[string]interface{}{"evil": true}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	reloaded := make(chan error, 10)
+	go func() {
+		_ = scanner.Watch(ctx, 10*time.Millisecond, func(err error) {
+			reloaded <- err
+		})
+	}()
+
+	writeFile(t, dir, "exceptions.yaml", `exceptions:
+  - namespace: misscan.test
+    justification: accepted risk
+`)
+
+	select {
+	case err := <-reloaded:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("exceptions were not reloaded")
+	}
+
+	assert.Equal(t, 0, countFailed(t, scanner, map[string]interface{}{"evil": true}))
+}
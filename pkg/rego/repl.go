@@ -0,0 +1,76 @@
+package rego
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/open-policy-agent/opa/rego"
+)
+
+// LoadedPolicy is a module loaded by the scanner, with its metadata as retrieved without an input
+type LoadedPolicy struct {
+	Filename  string          `json:"filename"`
+	Namespace string          `json:"namespace"`
+	Metadata  *StaticMetadata `json:"metadata"`
+}
+
+// LoadedPolicies returns the modules which would be evaluated by the scanner, ordered by namespace and filename
+func (s *Scanner) LoadedPolicies(ctx context.Context) ([]LoadedPolicy, error) {
+	s.reload.mu.RLock()
+	defer s.reload.mu.RUnlock()
+
+	if s.retriever == nil {
+		return nil, fmt.Errorf("policies have not been loaded")
+	}
+
+	policies := make([]LoadedPolicy, 0, len(s.policies))
+	for filename, module := range s.policies {
+		metadata, err := s.retriever.RetrieveMetadata(ctx, module)
+		if err != nil {
+			return nil, fmt.Errorf("retrieve metadata for %s: %w", filename, err)
+		}
+		policies = append(policies, LoadedPolicy{
+			Filename:  filename,
+			Namespace: getModuleNamespace(module),
+			Metadata:  metadata,
+		})
+	}
+	sort.Slice(policies, func(i, j int) bool {
+		if policies[i].Namespace != policies[j].Namespace {
+			return policies[i].Namespace < policies[j].Namespace
+		}
+		return policies[i].Filename < policies[j].Filename
+	})
+	return policies, nil
+}
+
+// Query evaluates an ad-hoc query against the given input, using the same compiled policies, data, schema, runtime
+// values and builtins as a scan. Queries are not cached.
+func (s *Scanner) Query(ctx context.Context, query string, input interface{}) (rego.ResultSet, error) {
+	s.reload.mu.RLock()
+	defer s.reload.mu.RUnlock()
+
+	if s.compiler == nil {
+		return nil, fmt.Errorf("policies have not been loaded")
+	}
+	compiler, err := s.completeCompiler()
+	if err != nil {
+		return nil, err
+	}
+
+	regoOptions := []func(*rego.Rego){
+		rego.Query(query),
+		rego.Compiler(compiler),
+		rego.Store(s.store),
+		rego.Runtime(s.runtimeValues),
+	}
+	if input != nil {
+		regoOptions = append(regoOptions, rego.Input(input))
+	}
+	if s.inputSchema != nil {
+		regoOptions = append(regoOptions, rego.Schemas(s.inputSchemaSet()))
+	}
+
+	return rego.New(regoOptions...).Eval(ctx)
+}
@@ -0,0 +1,68 @@
+package rego
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/khulnasoft-lab/misscan/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_RegoScanning_Query(t *testing.T) {
+	srcFS := CreateFS(t, map[string]string{
+		"policies/test.rego": `
+# METADATA
+# title: Evil input
+# custom:
+#   avd_id: AVD-TEST-0001
+package misscan.test
+
+deny {
+    input.evil
+}
+`,
+		"policies/lib.rego": `
+package lib.test
+
+double(x) := x * 2
+`,
+	})
+
+	scanner := NewScanner(types.SourceJSON)
+
+	_, err := scanner.Query(context.TODO(), "true", nil)
+	require.ErrorContains(t, err, "policies have not been loaded")
+
+	require.NoError(
+		t,
+		scanner.LoadPolicies(false, false, srcFS, []string{"policies"}, nil),
+	)
+
+	policies, err := scanner.LoadedPolicies(context.TODO())
+	require.NoError(t, err)
+	require.Len(t, policies, 2)
+	assert.Equal(t, "lib.test", policies[0].Namespace)
+	assert.Equal(t, "misscan.test", policies[1].Namespace)
+	assert.Equal(t, "policies/test.rego", policies[1].Filename)
+	assert.Equal(t, "AVD-TEST-0001", policies[1].Metadata.AVDID)
+	assert.Equal(t, "Evil input", policies[1].Metadata.Title)
+
+	set, err := scanner.Query(context.TODO(), "data.misscan.test.deny", map[string]interface{}{"evil": true})
+	require.NoError(t, err)
+	require.Len(t, set, 1)
+	assert.Equal(t, true, set[0].Expressions[0].Value)
+
+	set, err = scanner.Query(context.TODO(), "data.misscan.test.deny", map[string]interface{}{})
+	require.NoError(t, err)
+	assert.Empty(t, set)
+
+	set, err = scanner.Query(context.TODO(), "x := data.lib.test.double(input.n)", map[string]interface{}{"n": 21})
+	require.NoError(t, err)
+	require.Len(t, set, 1)
+	assert.Equal(t, json.Number("42"), set[0].Bindings["x"])
+
+	_, err = scanner.Query(context.TODO(), "input.", nil)
+	require.Error(t, err)
+}
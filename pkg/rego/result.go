@@ -3,9 +3,12 @@ package rego
 import (
 	"fmt"
 	"io/fs"
+	"path"
 	"strconv"
+	"strings"
 
 	"github.com/khulnasoft-lab/misscan/pkg/scan"
+	"github.com/khulnasoft-lab/misscan/pkg/severity"
 	misscanTypes "github.com/khulnasoft-lab/misscan/pkg/types"
 	"github.com/open-policy-agent/opa/rego"
 )
@@ -22,6 +25,13 @@ type regoResult struct {
 	FSKey        string
 	FS           fs.FS
 	Parent       *regoResult
+
+	// optional per-finding overrides
+	Severity    string
+	Remediation string
+	Links       []string
+	Fields      map[string]interface{}
+	Fix         *scan.Fix
 }
 
 func (r regoResult) GetMetadata() misscanTypes.Metadata {
@@ -111,53 +121,210 @@ func parseCause(cause map[string]interface{}) regoResult {
 			result.Parent = &parentResult
 		}
 	}
+	parseOverrides(cause, &result)
 	return result
 }
 
+// parseOverrides reads the optional severity, remediation, links, fields and fix which a rule can add to its result,
+// e.g. object.union(result.new(msg, rule), {"severity": "CRITICAL"})
+func parseOverrides(cause map[string]interface{}, result *regoResult) {
+	if sev, ok := cause["severity"].(string); ok {
+		result.Severity = sev
+	}
+	if remediation, ok := cause["remediation"].(string); ok {
+		result.Remediation = remediation
+	}
+	if links, ok := cause["links"].([]interface{}); ok {
+		for _, link := range links {
+			result.Links = append(result.Links, fmt.Sprintf("%s", link))
+		}
+	}
+	if fields, ok := cause["fields"].(map[string]interface{}); ok {
+		result.Fields = fields
+	}
+	if fix, ok := cause["fix"].(map[string]interface{}); ok {
+		result.Fix = parseFix(fix)
+	}
+}
+
+func parseFix(raw map[string]interface{}) *scan.Fix {
+	var fix scan.Fix
+	if description, ok := raw["description"].(string); ok {
+		fix.Description = description
+	}
+	if edits, ok := raw["edits"].([]interface{}); ok {
+		for _, rawEdit := range edits {
+			edit, ok := rawEdit.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			var converted scan.FixEdit
+			if filepath, ok := edit["filepath"]; ok {
+				converted.Filename = fmt.Sprintf("%s", filepath)
+			}
+			if start, ok := edit["startline"]; ok {
+				converted.StartLine = parseLineNumber(start)
+			}
+			if end, ok := edit["endline"]; ok {
+				converted.EndLine = parseLineNumber(end)
+			}
+			if content, ok := edit["content"].(string); ok {
+				converted.Content = content
+			}
+			fix.Edits = append(fix.Edits, converted)
+		}
+	}
+	return &fix
+}
+
+// applyOverrides copies any per-finding overrides onto the converted result
+func (s *Scanner) applyOverrides(result *scan.Result, regoResult *regoResult) {
+	if regoResult.Severity != "" {
+		if sev := severity.StringToSeverity(regoResult.Severity); sev != severity.None {
+			result.OverrideSeverity(sev)
+		} else {
+			s.debug.Log("Ignoring invalid severity %q in result of %s.%s", regoResult.Severity, result.RegoNamespace(), result.RegoRule())
+		}
+	}
+	if regoResult.Remediation != "" {
+		result.OverrideRemediation(regoResult.Remediation)
+	}
+	if len(regoResult.Links) > 0 {
+		result.AddLinks(regoResult.Links...)
+	}
+	for key, value := range regoResult.Fields {
+		result.SetField(key, value)
+	}
+	if regoResult.Fix != nil {
+		result.SetFix(*regoResult.Fix)
+	}
+}
+
 func parseLineNumber(raw interface{}) int {
 	str := fmt.Sprintf("%s", raw)
 	n, _ := strconv.Atoi(str)
 	return n
 }
 
-func (s *Scanner) convertResults(set rego.ResultSet, input Input, namespace string, rule string, traces []string) scan.Results {
+func (s *Scanner) convertResults(set rego.ResultSet, input Input, namespace string, rule string, traces []string, explainer *explainTracer) scan.Results {
 	var results scan.Results
+	for _, value := range resultValues(set) {
+		s.addResult(&results, parseResult(value), input, namespace, rule, traces)
+		explainer.annotate(&results[len(results)-1], value)
+	}
+	return results
+}
 
-	offset := 0
-	if input.Contents != nil {
-		if xx, ok := input.Contents.(map[string]interface{}); ok {
-			if md, ok := xx["__misscan_metadata"]; ok {
-				if md2, ok := md.(map[string]interface{}); ok {
-					if sl, ok := md2["offset"]; ok {
-						offset, _ = sl.(int)
-					}
-				}
+// convertCombinedResults converts the results of a rule evaluated against several inputs at once. Each result is
+// attributed to the input whose path and filesystem match the filepath and fskey of the result; results which cannot be
+// attributed are reported against the first input. The index of the input each result is attributed to, or -1 for
+// results which could not be attributed, is returned alongside the results.
+func (s *Scanner) convertCombinedResults(set rego.ResultSet, inputs []Input, namespace string, rule string, traces []string, explainer *explainTracer) (scan.Results, []int) {
+	var results scan.Results
+	var attributed []int
+	var fsKeys []string
+	for _, value := range resultValues(set) {
+		result := parseResult(value)
+		if result.FSKey != "" && fsKeys == nil {
+			fsKeys = make([]string, len(inputs))
+			for i, input := range inputs {
+				fsKeys[i] = misscanTypes.CreateFSKey(input.FS)
 			}
 		}
+		i, ok := resolveInput(result, inputs, fsKeys)
+		if !ok {
+			s.debug.Log("Result of %s.%s could not be attributed to an input: filepath=%q fskey=%q", namespace, rule, result.Filepath, result.FSKey)
+			s.addResult(&results, result, inputs[0], namespace, rule, traces)
+			explainer.annotate(&results[len(results)-1], value)
+			attributed = append(attributed, -1)
+			continue
+		}
+		attributed = append(attributed, i)
+		s.addResult(&results, result, inputs[i], namespace, rule, traces)
+		explainer.annotate(&results[len(results)-1], value)
+	}
+	return results, attributed
+}
+
+// resolveInput returns the index of the input matching the filepath and fskey of a combined result. A result with a
+// filepath is attributed to the first matching input; a result with only an fskey must match exactly one input.
+func resolveInput(result *regoResult, inputs []Input, fsKeys []string) (int, bool) {
+	if len(inputs) == 1 {
+		return 0, true
 	}
+	if result.Filepath == "" && result.FSKey == "" {
+		return 0, false
+	}
+	match := -1
+	for i, input := range inputs {
+		if result.Filepath != "" && !samePath(result.Filepath, input.Path) {
+			continue
+		}
+		if result.FSKey != "" && result.FSKey != fsKeys[i] {
+			continue
+		}
+		if result.Filepath != "" {
+			return i, true
+		}
+		if match >= 0 {
+			return 0, false
+		}
+		match = i
+	}
+	return match, match >= 0
+}
+
+func samePath(a, b string) bool {
+	return strings.TrimPrefix(path.Clean(a), "/") == strings.TrimPrefix(path.Clean(b), "/")
+}
+
+func resultValues(set rego.ResultSet) []interface{} {
+	var values []interface{}
 	for _, result := range set {
 		for _, expression := range result.Expressions {
-			values, ok := expression.Value.([]interface{})
-			if !ok {
-				values = []interface{}{expression.Value}
+			if list, ok := expression.Value.([]interface{}); ok {
+				values = append(values, list...)
+			} else {
+				values = append(values, expression.Value)
 			}
+		}
+	}
+	return values
+}
 
-			for _, value := range values {
-				regoResult := parseResult(value)
-				regoResult.FS = input.FS
-				if regoResult.Filepath == "" && input.Path != "" {
-					regoResult.Filepath = input.Path
-				}
-				if regoResult.Message == "" {
-					regoResult.Message = fmt.Sprintf("Rego policy rule: %s.%s", namespace, rule)
-				}
-				regoResult.StartLine += offset
-				regoResult.EndLine += offset
-				results.AddRego(regoResult.Message, namespace, rule, traces, regoResult)
+func inputOffset(input Input) int {
+	if contents, ok := input.Contents.(map[string]interface{}); ok {
+		if metadata, ok := contents["__misscan_metadata"].(map[string]interface{}); ok {
+			offset, _ := metadata["offset"].(int)
+			return offset
+		}
+	}
+	return 0
+}
+
+func (s *Scanner) addResult(results *scan.Results, regoResult *regoResult, input Input, namespace string, rule string, traces []string) {
+	offset := inputOffset(input)
+	regoResult.FS = input.FS
+	if regoResult.Filepath == "" && input.Path != "" {
+		regoResult.Filepath = input.Path
+	}
+	if regoResult.Message == "" {
+		regoResult.Message = fmt.Sprintf("Rego policy rule: %s.%s", namespace, rule)
+	}
+	regoResult.StartLine += offset
+	regoResult.EndLine += offset
+	if regoResult.Fix != nil {
+		for i := range regoResult.Fix.Edits {
+			edit := &regoResult.Fix.Edits[i]
+			if edit.Filename == "" {
+				edit.Filename = regoResult.Filepath
 			}
+			edit.StartLine += offset
+			edit.EndLine += offset
 		}
 	}
-	return results
+	results.AddRego(regoResult.Message, namespace, rule, traces, regoResult)
+	s.applyOverrides(&(*results)[len(*results)-1], regoResult)
 }
 
 func (s *Scanner) embellishResultsWithRuleMetadata(results scan.Results, metadata StaticMetadata) scan.Results {
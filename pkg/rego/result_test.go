@@ -102,3 +102,36 @@ func Test_parseResult(t *testing.T) {
 		})
 	}
 }
+
+func Test_resolveInput(t *testing.T) {
+	inputs := []Input{
+		{Path: "/a.yaml"},
+		{Path: "b.yaml"},
+		{Path: "b.yaml"},
+	}
+	fsKeys := []string{"one", "two", "three"}
+
+	testCases := []struct {
+		name   string
+		result regoResult
+		want   int
+		ok     bool
+	}{
+		{name: "no location", result: regoResult{}, ok: false},
+		{name: "filepath", result: regoResult{Filepath: "a.yaml"}, want: 0, ok: true},
+		{name: "filepath and fskey", result: regoResult{Filepath: "b.yaml", FSKey: "three"}, want: 2, ok: true},
+		{name: "fskey", result: regoResult{FSKey: "two"}, want: 1, ok: true},
+		{name: "unknown filepath", result: regoResult{Filepath: "c.yaml"}, ok: false},
+		{name: "mismatched fskey", result: regoResult{Filepath: "a.yaml", FSKey: "two"}, ok: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := resolveInput(&tc.result, inputs, fsKeys)
+			assert.Equal(t, tc.ok, ok)
+			if tc.ok {
+				assert.Equal(t, tc.want, got)
+			}
+		})
+	}
+}
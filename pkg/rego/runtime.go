@@ -2,20 +2,17 @@ package rego
 
 import (
 	"os"
-	"strings"
 
 	"github.com/open-policy-agent/opa/ast"
 	"github.com/open-policy-agent/opa/version"
 )
 
-func addRuntimeValues() *ast.Term {
+// addRuntimeValues builds the value of opa.runtime(), exposing only the allowed environment variables
+func addRuntimeValues(allowedEnv []string) *ast.Term {
 	env := ast.NewObject()
-	for _, pair := range os.Environ() {
-		parts := strings.SplitN(pair, "=", 2)
-		if len(parts) == 1 {
-			env.Insert(ast.StringTerm(parts[0]), ast.NullTerm())
-		} else if len(parts) > 1 {
-			env.Insert(ast.StringTerm(parts[0]), ast.StringTerm(parts[1]))
+	for _, name := range allowedEnv {
+		if value, ok := os.LookupEnv(name); ok {
+			env.Insert(ast.StringTerm(name), ast.StringTerm(value))
 		}
 	}
 
@@ -0,0 +1,149 @@
+package rego
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/open-policy-agent/opa/ast"
+)
+
+// Sandbox restricts what loaded policies can access. The default sandbox exposes no environment variables and blocks
+// builtins which make network requests or read the runtime environment.
+//
+// The sandbox applies to every loaded module regardless of its PolicySource, including embedded policies and
+// libraries, which do not use any of the blocked builtins. Applying it to all sources keeps the compiler capabilities
+// the same for every module, so a policy cannot reach a blocked builtin through an embedded library.
+type Sandbox struct {
+	// AllowedEnv lists the environment variables exposed to policies through opa.runtime().env. Allowing any variable
+	// also allows opa.runtime, even if it is listed in BlockedBuiltins.
+	AllowedEnv []string
+	// BlockedBuiltins lists builtins which policies may not call - modules calling them are not loaded
+	BlockedBuiltins []string
+}
+
+func DefaultSandbox() Sandbox {
+	return Sandbox{
+		BlockedBuiltins: []string{"http.send", "net.lookup_ip_addr", "opa.runtime"},
+	}
+}
+
+// capabilities returns the capabilities of this version of OPA, less the blocked builtins
+func (sb Sandbox) capabilities() *ast.Capabilities {
+	capabilities := ast.CapabilitiesForThisVersion()
+	if len(sb.BlockedBuiltins) == 0 {
+		return capabilities
+	}
+	blocked := sb.blocked()
+	builtins := make([]*ast.Builtin, 0, len(capabilities.Builtins))
+	for _, builtin := range capabilities.Builtins {
+		if _, ok := blocked[builtin.Name]; !ok {
+			builtins = append(builtins, builtin)
+		}
+	}
+	capabilities.Builtins = builtins
+	return capabilities
+}
+
+func (sb Sandbox) blocked() map[string]struct{} {
+	blocked := make(map[string]struct{}, len(sb.BlockedBuiltins))
+	for _, name := range sb.BlockedBuiltins {
+		blocked[name] = struct{}{}
+	}
+	if len(sb.AllowedEnv) > 0 {
+		delete(blocked, ast.OPARuntime.Name)
+	}
+	return blocked
+}
+
+// SandboxViolation is a call to a blocked builtin
+type SandboxViolation struct {
+	Filename string `json:"filename"`
+	Row      int    `json:"row"`
+	Col      int    `json:"col"`
+	Builtin  string `json:"builtin"`
+}
+
+func (v SandboxViolation) Error() string {
+	return fmt.Sprintf("%s:%d: call to blocked builtin %s", v.Filename, v.Row, v.Builtin)
+}
+
+// Violations returns every call to a blocked builtin in the given modules, ordered by file and position
+func (sb Sandbox) Violations(modules map[string]*ast.Module) []SandboxViolation {
+	if len(sb.BlockedBuiltins) == 0 {
+		return nil
+	}
+	blocked := sb.blocked()
+
+	var violations []SandboxViolation
+	for filename, module := range modules {
+		check := func(operator *ast.Term) {
+			ref, ok := operator.Value.(ast.Ref)
+			if !ok {
+				return
+			}
+			name := ref.String()
+			if _, ok := blocked[name]; !ok {
+				return
+			}
+			violation := SandboxViolation{
+				Filename: filename,
+				Builtin:  name,
+			}
+			if operator.Location != nil {
+				violation.Row = operator.Location.Row
+				violation.Col = operator.Location.Col
+			}
+			violations = append(violations, violation)
+		}
+		ast.WalkExprs(module, func(expr *ast.Expr) bool {
+			if terms, ok := expr.Terms.([]*ast.Term); ok && expr.IsCall() {
+				check(terms[0])
+			}
+			return false
+		})
+		ast.WalkTerms(module, func(term *ast.Term) bool {
+			if call, ok := term.Value.(ast.Call); ok && len(call) > 0 {
+				check(call[0])
+			}
+			return false
+		})
+	}
+
+	sort.Slice(violations, func(i, j int) bool {
+		if violations[i].Filename != violations[j].Filename {
+			return violations[i].Filename < violations[j].Filename
+		}
+		if violations[i].Row != violations[j].Row {
+			return violations[i].Row < violations[j].Row
+		}
+		return violations[i].Col < violations[j].Col
+	})
+	return violations
+}
+
+// enforceSandbox removes modules which call blocked builtins, recording a diagnostic for each call. In strict mode the
+// load fails instead.
+func (s *Scanner) enforceSandbox() error {
+	violations := s.sandbox.Violations(s.policies)
+	for _, violation := range violations {
+		s.debug.Log("Sandbox violation: %s", violation.Error())
+		s.diagnostics = append(s.diagnostics, Diagnostic{
+			Filename: violation.Filename,
+			Row:      violation.Row,
+			Col:      violation.Col,
+			Code:     "rego_sandbox_error",
+			Message:  fmt.Sprintf("call to blocked builtin %s", violation.Builtin),
+			Pruned:   !s.strict,
+		})
+	}
+	if len(violations) == 0 {
+		return nil
+	}
+	if s.strict {
+		return fmt.Errorf("%d sandbox violation(s), first: %w", len(violations), violations[0])
+	}
+	for _, violation := range violations {
+		delete(s.policies, violation.Filename)
+	}
+	return nil
+}
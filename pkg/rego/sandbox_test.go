@@ -0,0 +1,113 @@
+package rego
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/khulnasoft-lab/misscan/pkg/scanners/options"
+	"github.com/khulnasoft-lab/misscan/pkg/types"
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_RegoScanning_Sandbox(t *testing.T) {
+
+	t.Setenv("MISSCAN_SECRET", "hunter2")
+
+	srcFS := CreateFS(t, map[string]string{
+		"policies/runtime.rego": `
+package misscan.runtime
+
+deny[msg] {
+    msg := opa.runtime().env.MISSCAN_SECRET
+}
+`,
+		"policies/http.rego": `
+package misscan.http
+
+deny {
+    resp := http.send({"method": "GET", "url": "https://example.com"})
+    resp.status_code == 200
+}
+`,
+		"policies/safe.rego": `
+package misscan.safe
+
+deny {
+    input.evil
+}
+`,
+	})
+
+	t.Run("default sandbox", func(t *testing.T) {
+		scanner := NewScanner(types.SourceJSON)
+		require.NoError(
+			t,
+			scanner.LoadPolicies(false, false, srcFS, []string{"policies"}, nil),
+		)
+		assert.Len(t, scanner.policies, 1)
+		assert.Contains(t, scanner.policies, "policies/safe.rego")
+
+		diagnostics := scanner.Diagnostics()
+		require.Len(t, diagnostics, 2)
+		assert.Equal(t, "policies/http.rego", diagnostics[0].Filename)
+		assert.Equal(t, 5, diagnostics[0].Row)
+		assert.Equal(t, "rego_sandbox_error", diagnostics[0].Code)
+		assert.Equal(t, "call to blocked builtin http.send", diagnostics[0].Message)
+		assert.True(t, diagnostics[0].Pruned)
+		assert.Equal(t, "policies/runtime.rego", diagnostics[1].Filename)
+		assert.Equal(t, "call to blocked builtin opa.runtime", diagnostics[1].Message)
+	})
+
+	t.Run("strict", func(t *testing.T) {
+		scanner := NewScanner(types.SourceJSON, options.ScannerWithStrictCompilation(true))
+		err := scanner.LoadPolicies(false, false, srcFS, []string{"policies"}, nil)
+		require.ErrorContains(t, err, "policies/http.rego:5: call to blocked builtin http.send")
+	})
+
+	t.Run("runtime allowed without env", func(t *testing.T) {
+		scanner := NewScanner(types.SourceJSON, options.ScannerWithBlockedBuiltins("http.send"))
+		require.NoError(
+			t,
+			scanner.LoadPolicies(false, false, srcFS, []string{"policies"}, nil),
+		)
+		assert.Contains(t, scanner.policies, "policies/runtime.rego")
+
+		results, err := scanner.ScanInput(context.TODO(), Input{
+			Path:     "/evil.json",
+			Contents: map[string]interface{}{},
+		})
+		require.NoError(t, err)
+		for _, result := range results.GetFailed() {
+			assert.NotContains(t, result.Description(), os.Getenv("MISSCAN_SECRET"))
+		}
+	})
+
+	t.Run("allowed env", func(t *testing.T) {
+		scanner := NewScanner(types.SourceJSON, options.ScannerWithAllowedEnv("MISSCAN_SECRET"))
+		require.NoError(
+			t,
+			scanner.LoadPolicies(false, false, srcFS, []string{"policies"}, nil),
+		)
+		assert.Contains(t, scanner.policies, "policies/runtime.rego")
+		assert.NotContains(t, scanner.policies, "policies/http.rego")
+
+		results, err := scanner.ScanInput(context.TODO(), Input{
+			Path:     "/evil.json",
+			Contents: map[string]interface{}{},
+		})
+		require.NoError(t, err)
+		require.Len(t, results.GetFailed(), 1)
+		assert.Equal(t, "hunter2", results.GetFailed()[0].Description())
+	})
+
+	t.Run("builtin blocked by capabilities", func(t *testing.T) {
+		module := ast.MustParseModule("package misscan.http\n\nresp := http.send({})\n")
+		compiler := newCompiler(ast.NewSchemaSet(), Sandbox{BlockedBuiltins: []string{"http.send"}})
+		compiler.Compile(map[string]*ast.Module{"http.rego": module})
+		require.True(t, compiler.Failed())
+		assert.ErrorContains(t, compiler.Errors, "undefined function http.send")
+	})
+}
@@ -4,20 +4,27 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/khulnasoft-lab/misscan/pkg/debug"
 	"github.com/khulnasoft-lab/misscan/pkg/framework"
 	"github.com/khulnasoft-lab/misscan/pkg/rego/schemas"
 	"github.com/khulnasoft-lab/misscan/pkg/scan"
 	"github.com/khulnasoft-lab/misscan/pkg/scanners/options"
+	"github.com/khulnasoft-lab/misscan/pkg/severity"
 	"github.com/khulnasoft-lab/misscan/pkg/types"
 	"github.com/open-policy-agent/opa/ast"
 	"github.com/open-policy-agent/opa/rego"
 	"github.com/open-policy-agent/opa/storage"
+	"github.com/open-policy-agent/opa/topdown"
+	"golang.org/x/sync/errgroup"
 )
 
 var _ options.ConfigurableScanner = (*Scanner)(nil)
@@ -29,17 +36,38 @@ type Scanner struct {
 	dataDirs       []string
 	runtimeValues  *ast.Term
 	compiler       *ast.Compiler
+	compiled       map[string]*ast.Module // the modules passed to the compiler, so that a reload can tell which changed
+	deferred       *deferredCompile       // compiles every policy when a reload only compiled some of them
 	regoErrorLimit int
 	debug          debug.Logger
 	traceWriter    io.Writer
 	tracePerResult bool
+	explain        bool
 	retriever      *MetadataRetriever
 	policyFS       fs.FS
 	dataFS         fs.FS
-	frameworks     []framework.Framework
+	filter         PolicyFilter
 	spec           string
 	inputSchema    interface{} // unmarshalled into this from a json schema document
 	sourceType     types.Source
+	concurrency    int
+	queries        *queryCache
+	policyBundles  []string
+	bundleKey      string
+	coverage       *coverageTracer
+	exceptionsFile string
+	exceptions     []Exception
+	stats          *statsCollector
+	statsWriter    io.Writer
+	strict         bool
+	diagnostics    []Diagnostic
+	reload         *reloadState
+	sandbox        Sandbox
+	queryTimeout   time.Duration
+	precedence     []PolicySource
+	sources        map[string]PolicySource // the source each of the policies was loaded from
+	trimLibraries  bool
+	wasm           bool
 }
 
 func (s *Scanner) SetUseEmbeddedLibraries(b bool) {
@@ -52,8 +80,39 @@ func (s *Scanner) SetSpec(spec string) {
 
 func (s *Scanner) SetRegoOnly(bool) {}
 
+// SetFrameworks limits evaluation to policies in any of the given frameworks
 func (s *Scanner) SetFrameworks(frameworks []framework.Framework) {
-	s.frameworks = frameworks
+	s.filter.Frameworks = frameworks
+}
+
+// SetPolicyFilter replaces the filter which selects the policies to evaluate
+func (s *Scanner) SetPolicyFilter(filter PolicyFilter) {
+	s.filter = filter
+}
+
+// SetIncludedChecks limits evaluation to policies matching any of the given AVD IDs, aliases or long IDs
+func (s *Scanner) SetIncludedChecks(ids ...string) {
+	s.filter.IncludeIDs = ids
+}
+
+// SetExcludedChecks prevents evaluation of policies matching any of the given AVD IDs, aliases or long IDs
+func (s *Scanner) SetExcludedChecks(ids ...string) {
+	s.filter.ExcludeIDs = ids
+}
+
+// SetMinimumSeverity prevents evaluation of policies with a lower severity
+func (s *Scanner) SetMinimumSeverity(sev severity.Severity) {
+	s.filter.MinimumSeverity = sev
+}
+
+// SetIncludedServices limits evaluation to policies for the given providers or services, e.g. "aws" or "aws/s3"
+func (s *Scanner) SetIncludedServices(services ...string) {
+	s.filter.IncludeServices = services
+}
+
+// SetExcludedServices prevents evaluation of policies for the given providers or services, e.g. "aws" or "aws/s3"
+func (s *Scanner) SetExcludedServices(services ...string) {
+	s.filter.ExcludeServices = services
 }
 
 func (s *Scanner) SetUseEmbeddedPolicies(b bool) {
@@ -88,13 +147,22 @@ func (s *Scanner) SetDebugWriter(writer io.Writer) {
 }
 
 func (s *Scanner) SetTraceWriter(writer io.Writer) {
-	s.traceWriter = writer
+	if writer == nil {
+		s.traceWriter = nil
+		return
+	}
+	s.traceWriter = &syncWriter{w: writer}
 }
 
 func (s *Scanner) SetPerResultTracingEnabled(b bool) {
 	s.tracePerResult = b
 }
 
+// SetExplainEnabled records on each failed result the expressions of the rule which produced it
+func (s *Scanner) SetExplainEnabled(b bool) {
+	s.explain = b
+}
+
 func (s *Scanner) SetPolicyDirs(_ ...string) {
 	// NOTE: Policy dirs option not applicable for rego, policies are loaded on-demand by other scanners.
 }
@@ -117,6 +185,113 @@ func (s *Scanner) SetRegoErrorLimit(limit int) {
 	s.regoErrorLimit = limit
 }
 
+func (s *Scanner) SetPolicyBundles(paths ...string) {
+	s.policyBundles = append(s.policyBundles, paths...)
+}
+
+func (s *Scanner) SetPolicyBundleVerificationKey(path string) {
+	s.bundleKey = path
+}
+
+func (s *Scanner) SetCoverageEnabled(b bool) {
+	if !b {
+		s.coverage = nil
+		return
+	}
+	if s.coverage == nil {
+		s.coverage = newCoverageTracer()
+	}
+}
+
+// Coverage reports the lines of each loaded policy which have been executed by scans so far, or nil if coverage is not
+// enabled
+func (s *Scanner) Coverage() *CoverageReport {
+	if s.coverage == nil {
+		return nil
+	}
+	s.reload.mu.RLock()
+	defer s.reload.mu.RUnlock()
+	return NewCoverageReport(s.coverage.report(s.policies), s.policies)
+}
+
+func (s *Scanner) SetExceptionsFile(path string) {
+	s.exceptionsFile = path
+}
+
+// SetPolicyPrecedence sets the order, highest first, in which policies from different sources shadow each other when
+// they share an AVD ID or package. Sources which are not listed never shadow or are shadowed by each other.
+func (s *Scanner) SetPolicyPrecedence(sources ...string) {
+	s.precedence = make([]PolicySource, len(sources))
+	for i, source := range sources {
+		s.precedence[i] = PolicySource(source)
+	}
+}
+
+// SetLibraryTrimming removes library packages which none of the enabled checks depend on before the policies are
+// compiled, which shortens compilation when only a few checks are enabled. Checks are known to be disabled by the policy
+// filter and input selectors from their annotations or constant metadata; checks whose metadata is computed are kept,
+// along with every library they depend on.
+func (s *Scanner) SetLibraryTrimming(b bool) {
+	s.trimLibraries = b
+}
+
+// SetSandbox replaces the restrictions placed on loaded policies - see DefaultSandbox
+func (s *Scanner) SetSandbox(sandbox Sandbox) {
+	s.sandbox = sandbox
+	s.runtimeValues = addRuntimeValues(sandbox.AllowedEnv)
+}
+
+// SetAllowedEnv exposes the named environment variables to policies through opa.runtime().env, which allows policies
+// to call opa.runtime
+func (s *Scanner) SetAllowedEnv(names ...string) {
+	s.sandbox.AllowedEnv = names
+	s.runtimeValues = addRuntimeValues(names)
+}
+
+func (s *Scanner) SetBlockedBuiltins(names ...string) {
+	s.sandbox.BlockedBuiltins = names
+}
+
+// SetQueryTimeout limits how long a single query may run for. Rules which time out produce results with
+// scan.StatusError and the scan continues with the remaining rules.
+func (s *Scanner) SetQueryTimeout(timeout time.Duration) {
+	s.queryTimeout = timeout
+}
+
+// SetStrictCompilation causes policy loading to fail on any compile error, rather than pruning broken modules
+func (s *Scanner) SetStrictCompilation(b bool) {
+	s.strict = b
+}
+
+// SetStatsWriter writes the accumulated statistics as a JSON document after each scan
+func (s *Scanner) SetStatsWriter(writer io.Writer) {
+	s.statsWriter = writer
+}
+
+// Stats returns the compile time and per-rule evaluation statistics collected since the scanner was created
+func (s *Scanner) Stats() Stats {
+	return s.stats.snapshot()
+}
+
+func (s *Scanner) SetConcurrency(n int) {
+	if n < 1 {
+		n = 1
+	}
+	s.concurrency = n
+}
+
+// syncWriter serialises writes from concurrent evaluations to a shared writer
+type syncWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (w *syncWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.w.Write(p)
+}
+
 type DynamicMetadata struct {
 	Warning   bool
 	Filepath  string
@@ -139,7 +314,12 @@ func NewScanner(source types.Source, options ...options.ScannerOption) *Scanner
 			"appshield": {},
 			"misscan":   {},
 		},
-		runtimeValues: addRuntimeValues(),
+		runtimeValues: addRuntimeValues(nil),
+		sandbox:       DefaultSandbox(),
+		precedence:    DefaultPolicyPrecedence(),
+		concurrency:   1,
+		stats:         newStatsCollector(),
+		reload:        newReloadState(),
 	}
 	for _, opt := range options {
 		opt(s)
@@ -157,31 +337,53 @@ func (s *Scanner) SetParentDebugLogger(l debug.Logger) {
 	s.debug = l.Extend("rego")
 }
 
-func (s *Scanner) runQuery(ctx context.Context, query string, input interface{}, disableTracing bool) (rego.ResultSet, []string, error) {
+func (s *Scanner) runQuery(ctx context.Context, query string, input interface{}, disableTracing bool, tracers ...topdown.QueryTracer) (rego.ResultSet, []string, error) {
 
 	trace := (s.traceWriter != nil || s.tracePerResult) && !disableTracing
 
-	regoOptions := []func(*rego.Rego){
-		rego.Query(query),
-		rego.Compiler(s.compiler),
-		rego.Store(s.store),
-		rego.Runtime(s.runtimeValues),
-		rego.Trace(trace),
+	prepared, err := s.preparedQuery(ctx, query)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	if s.inputSchema != nil {
-		schemaSet := ast.NewSchemaSet()
-		schemaSet.Put(ast.MustParseRef("schema.input"), s.inputSchema)
-		regoOptions = append(regoOptions, rego.Schemas(schemaSet))
+	// tracers only observe the interpreter, so the Wasm engine is used only when nothing is tracing
+	if !trace && s.coverage == nil && len(tracers) == 0 {
+		if wasmQuery, ok := s.queries.wasmQuery(query); ok {
+			prepared = wasmQuery
+		}
 	}
 
+	var evalOptions []rego.EvalOption
 	if input != nil {
-		regoOptions = append(regoOptions, rego.Input(input))
+		evalOptions = append(evalOptions, rego.EvalInput(input))
+	}
+
+	var tracer *topdown.BufferTracer
+	if trace {
+		tracer = topdown.NewBufferTracer()
+		evalOptions = append(evalOptions, rego.EvalQueryTracer(tracer))
+	}
+
+	if s.coverage != nil {
+		evalOptions = append(evalOptions, rego.EvalQueryTracer(s.coverage))
+	}
+
+	for _, tracer := range tracers {
+		evalOptions = append(evalOptions, rego.EvalQueryTracer(tracer))
 	}
 
-	instance := rego.New(regoOptions...)
-	set, err := instance.Eval(ctx)
+	queryCtx := ctx
+	if s.queryTimeout > 0 {
+		var cancel context.CancelFunc
+		queryCtx, cancel = context.WithTimeout(ctx, s.queryTimeout)
+		defer cancel()
+	}
+
+	set, err := prepared.Eval(queryCtx, evalOptions...)
 	if err != nil {
+		if ctx.Err() == nil && errors.Is(queryCtx.Err(), context.DeadlineExceeded) {
+			return nil, nil, &QueryTimeoutError{Query: query, Timeout: s.queryTimeout}
+		}
 		return nil, nil, err
 	}
 
@@ -189,12 +391,13 @@ func (s *Scanner) runQuery(ctx context.Context, query string, input interface{},
 	var traces []string
 
 	if trace {
+		// buffer the trace so that it is written in one piece when evaluations run concurrently
+		traceBuffer := bytes.NewBuffer([]byte{})
+		topdown.PrettyTrace(traceBuffer, *tracer)
 		if s.traceWriter != nil {
-			rego.PrintTrace(s.traceWriter, instance)
+			_, _ = s.traceWriter.Write(traceBuffer.Bytes())
 		}
 		if s.tracePerResult {
-			traceBuffer := bytes.NewBuffer([]byte{})
-			rego.PrintTrace(traceBuffer, instance)
 			traces = strings.Split(traceBuffer.String(), "\n")
 		}
 	}
@@ -215,13 +418,85 @@ func GetInputsContents(inputs []Input) []any {
 	return results
 }
 
+// evaluation is a single enforced rule to be applied to the given inputs
+type evaluation struct {
+	namespace string
+	rule      string
+	inputs    []Input
+	combined  bool
+	metadata  *StaticMetadata
+}
+
 func (s *Scanner) ScanInput(ctx context.Context, inputs ...Input) (scan.Results, error) {
 
 	s.debug.Log("Scanning %d inputs...", len(inputs))
 
+	// hold on to the current policies until the scan completes, even if they are reloaded in the meantime
+	s.reload.mu.RLock()
+	defer s.reload.mu.RUnlock()
+
+	evaluations, err := s.planEvaluations(ctx, inputs)
+	if err != nil {
+		return nil, err
+	}
+
+	// each evaluation writes to its own slot so results are returned in a deterministic order
+	evaluated := make([]scan.Results, len(evaluations))
+
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(s.concurrency)
+	for i, e := range evaluations {
+		if gctx.Err() != nil {
+			break
+		}
+		g.Go(func() error {
+			if err := gctx.Err(); err != nil {
+				return err
+			}
+			start := time.Now()
+			ruleResults, err := s.applyRule(gctx, e.namespace, e.rule, e.inputs, e.combined)
+			if err != nil {
+				return err
+			}
+			s.stats.recordEvaluation(e.namespace, e.rule, len(e.inputs), time.Since(start), ruleResults)
+			evaluated[i] = s.embellishResultsWithRuleMetadata(ruleResults, *e.metadata)
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	var results scan.Results
+	for _, ruleResults := range evaluated {
+		results = append(results, ruleResults...)
+	}
+	results = s.applyExceptions(results)
+
+	s.stats.recordScan(len(inputs), results)
+	if s.statsWriter != nil {
+		if err := s.Stats().WriteJSON(s.statsWriter); err != nil {
+			s.debug.Log("Failed to write scan statistics: %s", err)
+		}
+	}
+	return results, nil
+}
 
-	for _, module := range s.policies {
+// planEvaluations builds the ordered list of (module, rule, input) evaluations required to scan the given inputs
+func (s *Scanner) planEvaluations(ctx context.Context, inputs []Input) ([]evaluation, error) {
+
+	names := make([]string, 0, len(s.policies))
+	for name := range s.policies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var evaluations []evaluation
+	for _, name := range names {
+		module := s.policies[name]
 
 		select {
 		case <-ctx.Done():
@@ -260,18 +535,31 @@ func (s *Scanner) ScanInput(ctx context.Context, inputs ...Input) (scan.Results,
 				continue
 			}
 			usedRules[ruleName] = struct{}{}
-			if isEnforcedRule(ruleName) {
-				ruleResults, err := s.applyRule(ctx, namespace, ruleName, inputs, staticMeta.InputOptions.Combined)
-				if err != nil {
-					return nil, err
-				}
-				results = append(results, s.embellishResultsWithRuleMetadata(ruleResults, *staticMeta)...)
+			if !isEnforcedRule(ruleName) {
+				continue
+			}
+			if staticMeta.InputOptions.Combined {
+				evaluations = append(evaluations, evaluation{
+					namespace: namespace,
+					rule:      ruleName,
+					inputs:    inputs,
+					combined:  true,
+					metadata:  staticMeta,
+				})
+				continue
+			}
+			for _, input := range inputs {
+				evaluations = append(evaluations, evaluation{
+					namespace: namespace,
+					rule:      ruleName,
+					inputs:    []Input{input},
+					metadata:  staticMeta,
+				})
 			}
 		}
-
 	}
 
-	return results, nil
+	return evaluations, nil
 }
 
 func isPolicyWithSubtype(sourceType types.Source) bool {
@@ -283,55 +571,6 @@ func isPolicyWithSubtype(sourceType types.Source) bool {
 	return false
 }
 
-func checkSubtype(ii map[string]interface{}, provider string, subTypes []SubType) bool {
-	if len(subTypes) == 0 {
-		return true
-	}
-
-	for _, st := range subTypes {
-		switch services := ii[provider].(type) {
-		case map[string]interface{}: // cloud
-			for service := range services {
-				if (service == st.Service) && (st.Provider == provider) {
-					return true
-				}
-			}
-		case string: // k8s - logic can be improved
-			if strings.EqualFold(services, st.Group) ||
-				strings.EqualFold(services, st.Version) ||
-				strings.EqualFold(services, st.Kind) {
-				return true
-			}
-		}
-	}
-	return false
-}
-
-func isPolicyApplicable(staticMetadata *StaticMetadata, inputs ...Input) bool {
-	for _, input := range inputs {
-		if ii, ok := input.Contents.(map[string]interface{}); ok {
-			for provider := range ii {
-				// TODO(simar): Add other providers
-				if !strings.Contains(strings.Join([]string{"kind", "aws", "azure"}, ","), provider) {
-					continue
-				}
-
-				if len(staticMetadata.InputOptions.Selectors) == 0 { // policy always applies if no selectors
-					return true
-				}
-
-				// check metadata for subtype
-				for _, s := range staticMetadata.InputOptions.Selectors {
-					if checkSubtype(ii, provider, s.Subtypes) {
-						return true
-					}
-				}
-			}
-		}
-	}
-	return false
-}
-
 func (s *Scanner) applyRule(ctx context.Context, namespace string, rule string, inputs []Input, combined bool) (scan.Results, error) {
 
 	// handle combined evaluations if possible
@@ -341,10 +580,14 @@ func (s *Scanner) applyRule(ctx context.Context, namespace string, rule string,
 	}
 
 	var results scan.Results
-	qualified := fmt.Sprintf("data.%s.%s", namespace, rule)
+	qualified := ruleQuery(namespace, rule)
 	for _, input := range inputs {
 		s.trace("INPUT", input)
 		if ignored, err := s.isIgnored(ctx, namespace, rule, input.Contents); err != nil {
+			if isQueryTimeout(err) {
+				s.addErrorResults(&results, err, namespace, rule, input)
+				continue
+			}
 			return nil, err
 		} else if ignored {
 			var result regoResult
@@ -354,12 +597,17 @@ func (s *Scanner) applyRule(ctx context.Context, namespace string, rule string,
 			results.AddIgnored(result)
 			continue
 		}
-		set, traces, err := s.runQuery(ctx, qualified, input.Contents, false)
+		explainer := s.explainer(namespace, rule)
+		set, traces, err := s.runQuery(ctx, qualified, input.Contents, false, explainer.tracers()...)
 		if err != nil {
+			if isQueryTimeout(err) {
+				s.addErrorResults(&results, err, namespace, rule, input)
+				continue
+			}
 			return nil, err
 		}
 		s.trace("RESULTSET", set)
-		ruleResults := s.convertResults(set, input, namespace, rule, traces)
+		ruleResults := s.convertResults(set, input, namespace, rule, traces, explainer)
 		if len(ruleResults) == 0 { // It passed because we didn't find anything wrong (NOT because it didn't exist)
 			var result regoResult
 			result.FS = input.FS
@@ -374,13 +622,22 @@ func (s *Scanner) applyRule(ctx context.Context, namespace string, rule string,
 	return results, nil
 }
 
+// applyRuleCombined evaluates the rule once against all of the inputs, so that it can compare them. Exceptions are
+// first evaluated against the combined inputs, as they always have been, so exceptions written against input[_] ignore
+// every input. They are then evaluated against each input on its own: results attributed to an excepted input are
+// ignored, and inputs without results pass.
 func (s *Scanner) applyRuleCombined(ctx context.Context, namespace string, rule string, inputs []Input) (scan.Results, error) {
 	if len(inputs) == 0 {
 		return nil, nil
 	}
 	var results scan.Results
-	qualified := fmt.Sprintf("data.%s.%s", namespace, rule)
+	qualified := ruleQuery(namespace, rule)
+
 	if ignored, err := s.isIgnored(ctx, namespace, rule, inputs); err != nil {
+		if isQueryTimeout(err) {
+			s.addErrorResults(&results, err, namespace, rule, inputs...)
+			return results, nil
+		}
 		return nil, err
 	} else if ignored {
 		for _, input := range inputs {
@@ -392,11 +649,103 @@ func (s *Scanner) applyRuleCombined(ctx context.Context, namespace string, rule
 		}
 		return results, nil
 	}
-	set, traces, err := s.runQuery(ctx, qualified, inputs, false)
+
+	skipped := make(map[int]struct{})
+	for i, input := range inputs {
+		if ignored, err := s.isIgnored(ctx, namespace, rule, input.Contents); err != nil {
+			if isQueryTimeout(err) {
+				s.addErrorResults(&results, err, namespace, rule, input)
+				skipped[i] = struct{}{}
+				continue
+			}
+			return nil, err
+		} else if ignored {
+			var result regoResult
+			result.FS = input.FS
+			result.Filepath = input.Path
+			result.Managed = true
+			results.AddIgnored(result)
+			skipped[i] = struct{}{}
+		}
+	}
+	if len(skipped) == len(inputs) {
+		return results, nil
+	}
+
+	explainer := s.explainer(namespace, rule)
+	set, traces, err := s.runQuery(ctx, qualified, inputs, false, explainer.tracers()...)
 	if err != nil {
+		if isQueryTimeout(err) {
+			for i, input := range inputs {
+				if _, ok := skipped[i]; !ok {
+					s.addErrorResults(&results, err, namespace, rule, input)
+				}
+			}
+			return results, nil
+		}
 		return nil, err
 	}
-	return s.convertResults(set, inputs[0], namespace, rule, traces), nil
+	s.trace("RESULTSET", set)
+
+	ruleResults, attributed := s.convertCombinedResults(set, inputs, namespace, rule, traces, explainer)
+	failed := make(map[int]struct{})
+	var unattributed bool
+	for j, result := range ruleResults {
+		i := attributed[j]
+		if _, ok := skipped[i]; ok {
+			continue
+		}
+		if i < 0 {
+			unattributed = true
+		}
+		failed[i] = struct{}{}
+		results = append(results, result)
+	}
+	if unattributed {
+		// a failure which cannot be attributed may belong to any of the inputs, so none of them have passed
+		return results, nil
+	}
+	for i, input := range inputs {
+		if _, ok := skipped[i]; ok {
+			continue
+		}
+		if _, ok := failed[i]; ok {
+			continue
+		}
+		var result regoResult
+		result.FS = input.FS
+		result.Filepath = input.Path
+		result.Managed = true
+		results.AddPassedRego(namespace, rule, traces, result)
+	}
+	return results, nil
+}
+
+// QueryTimeoutError is returned when a query takes longer than the configured query timeout
+type QueryTimeoutError struct {
+	Query   string
+	Timeout time.Duration
+}
+
+func (e *QueryTimeoutError) Error() string {
+	return fmt.Sprintf("query %s timed out after %s", e.Query, e.Timeout)
+}
+
+func isQueryTimeout(err error) bool {
+	var timeout *QueryTimeoutError
+	return errors.As(err, &timeout)
+}
+
+// addErrorResults records that the rule could not be evaluated against each of the inputs
+func (s *Scanner) addErrorResults(results *scan.Results, err error, namespace string, rule string, inputs ...Input) {
+	s.debug.Log("Failed to evaluate %s.%s: %s", namespace, rule, err)
+	for _, input := range inputs {
+		var result regoResult
+		result.FS = input.FS
+		result.Filepath = input.Path
+		result.Managed = true
+		results.AddErrorRego(err.Error(), namespace, rule, result)
+	}
 }
 
 // severity is now set with metadata, so deny/warn/violation now behave the same way
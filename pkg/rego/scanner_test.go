@@ -3,12 +3,17 @@ package rego
 import (
 	"bytes"
 	"context"
+	"encoding/json"
+	"fmt"
 	"io/fs"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/khulnasoft-lab/misscan/pkg/scan"
 	"github.com/khulnasoft-lab/misscan/pkg/severity"
 	"github.com/khulnasoft-lab/misscan/pkg/types"
 	"github.com/liamg/memoryfs"
@@ -342,7 +347,11 @@ deny_evil {
 `,
 	})
 
-	scanner := NewScanner(types.SourceJSON)
+	scanner := NewScanner(
+		types.SourceJSON,
+		options.ScannerWithAllowedEnv("MISSCAN_RUNTIME_VAL"),
+		options.ScannerWithBlockedBuiltins("http.send"),
+	)
 	require.NoError(
 		t,
 		scanner.LoadPolicies(false, false, srcFS, []string{"policies"}, nil),
@@ -976,3 +985,374 @@ deny {
 	assert.Equal(t, 0, len(results.GetPassed()))
 	assert.Equal(t, 0, len(results.GetIgnored()))
 }
+
+func Test_RegoScanning_WithConcurrency(t *testing.T) {
+
+	files := make(map[string]string)
+	for i := 0; i < 10; i++ {
+		files[fmt.Sprintf("policies/test%d.rego", i)] = fmt.Sprintf(`
+package misscan.test%d
+
+deny[res] {
+    input.evil
+    res := result.new(sprintf("evil %%d", [%d]), input)
+}
+
+warn {
+    input.suspicious
+}
+`, i, i)
+	}
+	srcFS := CreateFS(t, files)
+
+	var inputs []Input
+	for i := 0; i < 20; i++ {
+		inputs = append(inputs, Input{
+			Path: fmt.Sprintf("/input%d.json", i),
+			Contents: map[string]interface{}{
+				"evil":       i%2 == 0,
+				"suspicious": i%3 == 0,
+			},
+		})
+	}
+
+	scanWith := func(opts ...options.ScannerOption) scan.Results {
+		scanner := NewScanner(types.SourceJSON, opts...)
+		require.NoError(
+			t,
+			scanner.LoadPolicies(false, false, srcFS, []string{"policies"}, nil),
+		)
+		results, err := scanner.ScanInput(context.TODO(), inputs...)
+		require.NoError(t, err)
+		return results
+	}
+
+	sequential := scanWith()
+	concurrent := scanWith(
+		options.ScannerWithConcurrency(8),
+		options.ScannerWithTrace(bytes.NewBuffer(nil)),
+		options.ScannerWithDebug(bytes.NewBuffer(nil)),
+	)
+
+	require.Len(t, concurrent, len(sequential))
+	assert.Len(t, concurrent.GetFailed(), 10*(10+7))
+	for i := range sequential {
+		assert.Equal(t, sequential[i].Description(), concurrent[i].Description())
+		assert.Equal(t, sequential[i].Status(), concurrent[i].Status())
+		assert.Equal(t, sequential[i].RegoNamespace(), concurrent[i].RegoNamespace())
+		assert.Equal(t, sequential[i].RegoRule(), concurrent[i].RegoRule())
+		assert.Equal(t, sequential[i].Range().GetFilename(), concurrent[i].Range().GetFilename())
+	}
+}
+
+func Test_RegoScanning_WithCancelledContext(t *testing.T) {
+
+	srcFS := CreateFS(t, map[string]string{
+		"policies/test.rego": `
+package misscan.test
+
+deny {
+    input.evil
+}
+`,
+	})
+
+	scanner := NewScanner(types.SourceJSON, options.ScannerWithConcurrency(4))
+	require.NoError(
+		t,
+		scanner.LoadPolicies(false, false, srcFS, []string{"policies"}, nil),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := scanner.ScanInput(ctx, Input{
+		Path: "/evil.lol",
+		Contents: map[string]interface{}{
+			"evil": true,
+		},
+	})
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func Test_RegoScanning_WithResultOverrides(t *testing.T) {
+
+	srcFS := CreateFS(t, map[string]string{
+		"policies/test.rego": `# METADATA
+# title: SSH open to the world
+# scope: package
+# related_resources:
+# - https://example.com/ssh
+# custom:
+#   avd_id: AVD-TEST-0001
+#   severity: MEDIUM
+#   recommended_action: Restrict ingress
+package misscan.test
+
+deny[res] {
+    rule := input.rules[_]
+    rule.cidr == "0.0.0.0/0"
+    res := object.union(result.new("SSH is open to the world", rule), {
+        "severity": "CRITICAL",
+        "remediation": "Remove the 0.0.0.0/0 ingress rule",
+        "links": ["https://example.com/public-ssh"],
+        "fields": {"cidr": rule.cidr, "port": 22},
+        "fix": {
+            "description": "Restrict to the VPN range",
+            "edits": [{"startline": 2, "endline": 2, "content": "cidr: 10.0.0.0/8"}],
+        },
+    })
+}
+
+deny[res] {
+    rule := input.rules[_]
+    rule.cidr != "0.0.0.0/0"
+    res := object.union(result.new("SSH is open to a private range", rule), {"severity": "low"})
+}
+`,
+	})
+
+	scanner := NewScanner(types.SourceJSON)
+	require.NoError(
+		t,
+		scanner.LoadPolicies(false, false, srcFS, []string{"policies"}, nil),
+	)
+
+	results, err := scanner.ScanInput(context.TODO(), Input{
+		Path: "/sg.yaml",
+		Contents: map[string]interface{}{
+			"rules": []interface{}{
+				map[string]interface{}{"cidr": "0.0.0.0/0"},
+				map[string]interface{}{"cidr": "10.0.0.0/8"},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	failures := results.GetFailed()
+	require.Len(t, failures, 2)
+	sort.Slice(failures, func(i, j int) bool {
+		return failures[i].Description() < failures[j].Description()
+	})
+
+	private, public := failures[0], failures[1]
+
+	assert.Equal(t, severity.Low, private.Severity())
+	assert.Equal(t, "Restrict ingress", private.Remediation())
+	assert.Equal(t, []string{"https://example.com/ssh"}, private.Links())
+	assert.Nil(t, private.Fix())
+
+	assert.Equal(t, severity.Critical, public.Severity())
+	assert.Equal(t, severity.Medium, public.Rule().Severity)
+	assert.Equal(t, "Remove the 0.0.0.0/0 ingress rule", public.Remediation())
+	assert.Equal(t, []string{"https://example.com/public-ssh", "https://example.com/ssh"}, public.Links())
+	assert.Equal(t, map[string]interface{}{"cidr": "0.0.0.0/0", "port": json.Number("22")}, public.Fields())
+	assert.Equal(t, &scan.Fix{
+		Description: "Restrict to the VPN range",
+		Edits: []scan.FixEdit{
+			{Filename: "/sg.yaml", StartLine: 2, EndLine: 2, Content: "cidr: 10.0.0.0/8"},
+		},
+	}, public.Fix())
+
+	flat := public.Flatten()
+	assert.Equal(t, severity.Critical, flat.Severity)
+	assert.Equal(t, "Remove the 0.0.0.0/0 ingress rule", flat.Resolution)
+	assert.NotNil(t, flat.Fix)
+}
+
+func Test_RegoScanning_WithQueryTimeout(t *testing.T) {
+
+	srcFS := CreateFS(t, map[string]string{
+		"policies/slow.rego": `
+package misscan.slow
+
+deny {
+    n := numbers.range(1, 1000)
+    count([1 | n[_]; n[_]; n[_]]) > 0
+}
+`,
+		"policies/fast.rego": `
+package misscan.fast
+
+deny {
+    input.evil
+}
+`,
+	})
+
+	scanner := NewScanner(types.SourceJSON, options.ScannerWithQueryTimeout(100*time.Millisecond))
+	require.NoError(
+		t,
+		scanner.LoadPolicies(false, false, srcFS, []string{"policies"}, nil),
+	)
+
+	results, err := scanner.ScanInput(context.TODO(), Input{
+		Path: "/evil.json",
+		Contents: map[string]interface{}{
+			"evil": true,
+		},
+	})
+	require.NoError(t, err)
+
+	failed := results.GetFailed()
+	require.Len(t, failed, 1)
+	assert.Equal(t, "misscan.fast", failed[0].RegoNamespace())
+
+	errored := results.GetErrored()
+	require.Len(t, errored, 1)
+	assert.Equal(t, scan.StatusError, errored[0].Status())
+	assert.Equal(t, "misscan.slow", errored[0].RegoNamespace())
+	assert.Equal(t, "deny", errored[0].RegoRule())
+	assert.Equal(t, "query data.misscan.slow.deny timed out after 100ms", errored[0].ErrorReason())
+	assert.Equal(t, "/evil.json", errored[0].Range().GetFilename())
+	assert.Equal(t, errored[0].ErrorReason(), errored[0].Flatten().Error)
+}
+
+func Test_RegoScanning_CombinedAttribution(t *testing.T) {
+	srcFS := CreateFS(t, map[string]string{
+		"policies/test.rego": `
+package misscan.test
+
+__rego_input__ := {
+    "combine": true,
+}
+
+deny[res] {
+    some i, j
+    input[i].contents.name == input[j].contents.name
+    input[i].path > input[j].path
+    res := {"msg": "duplicate name", "filepath": input[i].path}
+}
+
+exception[rules] {
+    input.skip
+    rules := ["deny"]
+}
+`,
+	})
+
+	scanner := NewScanner(types.SourceJSON)
+	require.NoError(
+		t,
+		scanner.LoadPolicies(false, false, srcFS, []string{"policies"}, nil),
+	)
+
+	inputFS := make(map[string]fs.FS)
+	var inputs []Input
+	for _, input := range []struct {
+		path     string
+		contents map[string]interface{}
+	}{
+		{path: "a.json", contents: map[string]interface{}{"name": "x"}},
+		{path: "b.json", contents: map[string]interface{}{"name": "x"}},
+		{path: "c.json", contents: map[string]interface{}{"name": "y"}},
+		{path: "d.json", contents: map[string]interface{}{"name": "y", "skip": true}},
+	} {
+		inputFS[input.path] = CreateFS(t, map[string]string{input.path: "{}"})
+		inputs = append(inputs, Input{
+			Path:     input.path,
+			FS:       inputFS[input.path],
+			Contents: input.contents,
+		})
+	}
+
+	results, err := scanner.ScanInput(context.TODO(), inputs...)
+	require.NoError(t, err)
+
+	failed := results.GetFailed()
+	require.Len(t, failed, 1)
+	assert.Equal(t, "b.json", failed[0].Range().GetFilename())
+	assert.Equal(t, inputFS["b.json"], failed[0].Range().GetFS())
+
+	var passed []string
+	for _, result := range results.GetPassed() {
+		passed = append(passed, result.Range().GetFilename())
+	}
+	assert.ElementsMatch(t, []string{"a.json", "c.json"}, passed)
+
+	ignored := results.GetIgnored()
+	require.Len(t, ignored, 1)
+	assert.Equal(t, "d.json", ignored[0].Range().GetFilename())
+}
+
+func Test_RegoScanning_CombinedUnattributed(t *testing.T) {
+	srcFS := CreateFS(t, map[string]string{
+		"policies/test.rego": `
+package misscan.test
+
+__rego_input__ := {
+    "combine": true,
+}
+
+deny[res] {
+    count(input) > 1
+    res := {"msg": "too many inputs"}
+}
+`,
+	})
+
+	scanner := NewScanner(types.SourceJSON)
+	require.NoError(
+		t,
+		scanner.LoadPolicies(false, false, srcFS, []string{"policies"}, nil),
+	)
+
+	inputFS := CreateFS(t, map[string]string{"a.json": "{}", "b.json": "{}"})
+	results, err := scanner.ScanInput(context.TODO(),
+		Input{Path: "a.json", FS: inputFS, Contents: map[string]interface{}{}},
+		Input{Path: "b.json", FS: inputFS, Contents: map[string]interface{}{}},
+	)
+	require.NoError(t, err)
+
+	failed := results.GetFailed()
+	require.Len(t, failed, 1)
+	assert.Equal(t, "a.json", failed[0].Range().GetFilename())
+	assert.Equal(t, inputFS, failed[0].Range().GetFS())
+	assert.Empty(t, results.GetPassed())
+}
+
+func Test_RegoScanning_CombinedArrayException(t *testing.T) {
+	srcFS := CreateFS(t, map[string]string{
+		"policies/test.rego": `
+package misscan.test
+
+__rego_input__ := {
+    "combine": true,
+}
+
+deny[res] {
+    some i, j
+    input[i].contents.name == input[j].contents.name
+    input[i].path > input[j].path
+    res := {"msg": "duplicate name", "filepath": input[i].path}
+}
+
+exception[rules] {
+    input[_].contents.legacy
+    rules := ["deny"]
+}
+`,
+	})
+
+	scanner := NewScanner(types.SourceJSON)
+	require.NoError(
+		t,
+		scanner.LoadPolicies(false, false, srcFS, []string{"policies"}, nil),
+	)
+
+	results, err := scanner.ScanInput(context.TODO(),
+		Input{Path: "a.json", Contents: map[string]interface{}{"name": "x"}},
+		Input{Path: "b.json", Contents: map[string]interface{}{"name": "x", "legacy": true}},
+		Input{Path: "c.json", Contents: map[string]interface{}{"name": "y"}},
+	)
+	require.NoError(t, err)
+
+	// exceptions written against the combined inputs still ignore every input
+	assert.Empty(t, results.GetFailed())
+	assert.Empty(t, results.GetPassed())
+	var ignored []string
+	for _, result := range results.GetIgnored() {
+		ignored = append(ignored, result.Range().GetFilename())
+	}
+	assert.ElementsMatch(t, []string{"a.json", "b.json", "c.json"}, ignored)
+}
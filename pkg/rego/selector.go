@@ -0,0 +1,131 @@
+package rego
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/khulnasoft-lab/misscan/pkg/providers"
+	"github.com/khulnasoft-lab/misscan/pkg/state"
+	"github.com/khulnasoft-lab/misscan/pkg/types"
+)
+
+// cloudServices maps each provider in the cloud state to its services, using the same names as the cloud input
+var cloudServices = func() map[providers.Provider]map[string]struct{} {
+	services := make(map[providers.Provider]map[string]struct{})
+	stateType := reflect.TypeOf(state.State{})
+	for i := 0; i < stateType.NumField(); i++ {
+		providerField := stateType.Field(i)
+		provider := providers.Provider(strings.ToLower(providerField.Name))
+		services[provider] = make(map[string]struct{})
+		for j := 0; j < providerField.Type.NumField(); j++ {
+			serviceField := providerField.Type.Field(j)
+			if serviceField.Name == "Meta" {
+				continue
+			}
+			services[provider][strings.ToLower(serviceField.Name)] = struct{}{}
+		}
+	}
+	return services
+}()
+
+// isPolicyApplicable returns true if any of the inputs is a kubernetes resource or cloud state selected by the
+// policy's input selectors. Policies without selectors apply to every recognised input.
+func isPolicyApplicable(staticMetadata *StaticMetadata, inputs ...Input) bool {
+	selectors := staticMetadata.InputOptions.Selectors
+	for _, input := range inputs {
+		contents, ok := input.Contents.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		if _, ok := contents["kind"]; ok {
+			if len(selectors) == 0 || matchesKubernetesSelectors(selectors, contents) {
+				return true
+			}
+			continue
+		}
+
+		for key, services := range contents {
+			provider := providers.Provider(key)
+			if _, ok := cloudServices[provider]; !ok {
+				continue
+			}
+			if len(selectors) == 0 || matchesCloudSelectors(selectors, provider, services) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func matchesKubernetesSelectors(selectors []Selector, resource map[string]interface{}) bool {
+	for _, selector := range selectors {
+		if selector.Type != string(types.SourceKubernetes) && selector.Type != string(types.SourceRbac) {
+			continue
+		}
+		if len(selector.Subtypes) == 0 {
+			return true
+		}
+		for _, subtype := range selector.Subtypes {
+			if matchesKubernetesSubtype(subtype, resource) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matchesKubernetesSubtype compares each field set on the subtype against the resource's apiVersion, kind and namespace
+func matchesKubernetesSubtype(subtype SubType, resource map[string]interface{}) bool {
+	apiVersion, _ := resource["apiVersion"].(string)
+	group, version := "", apiVersion
+	if i := strings.LastIndex(apiVersion, "/"); i >= 0 {
+		group, version = apiVersion[:i], apiVersion[i+1:]
+	}
+	kind, _ := resource["kind"].(string)
+	namespace := "default"
+	if metadata, ok := resource["metadata"].(map[string]interface{}); ok {
+		if ns, ok := metadata["namespace"].(string); ok && ns != "" {
+			namespace = ns
+		}
+	}
+
+	if subtype.Group != "" && !strings.EqualFold(subtype.Group, group) &&
+		!(strings.EqualFold(subtype.Group, "core") && group == "") {
+		return false
+	}
+	if subtype.Version != "" && !strings.EqualFold(subtype.Version, version) {
+		return false
+	}
+	if subtype.Kind != "" && !strings.EqualFold(subtype.Kind, kind) {
+		return false
+	}
+	if subtype.Namespace != "" && subtype.Namespace != namespace {
+		return false
+	}
+	return true
+}
+
+func matchesCloudSelectors(selectors []Selector, provider providers.Provider, services interface{}) bool {
+	present, _ := services.(map[string]interface{})
+	for _, selector := range selectors {
+		if selector.Type != string(types.SourceCloud) {
+			continue
+		}
+		if len(selector.Subtypes) == 0 {
+			return true
+		}
+		for _, subtype := range selector.Subtypes {
+			if providers.Provider(subtype.Provider) != provider {
+				continue
+			}
+			if subtype.Service == "" {
+				return true
+			}
+			if _, ok := present[subtype.Service]; ok {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,152 @@
+package rego
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_isPolicyApplicable(t *testing.T) {
+
+	deployment := map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata": map[string]interface{}{
+			"name":      "app",
+			"namespace": "prod",
+		},
+	}
+	pod := map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Pod",
+	}
+
+	tests := []struct {
+		name      string
+		selectors []Selector
+		contents  map[string]interface{}
+		want      bool
+	}{
+		{
+			name:     "no selectors",
+			contents: pod,
+			want:     true,
+		},
+		{
+			name:     "unrecognised input",
+			contents: map[string]interface{}{"foo": "bar"},
+			want:     false,
+		},
+		{
+			name:      "kubernetes selector without subtypes",
+			selectors: []Selector{{Type: "kubernetes"}},
+			contents:  pod,
+			want:      true,
+		},
+		{
+			name: "kubernetes group, version and kind",
+			selectors: []Selector{{Type: "kubernetes", Subtypes: []SubType{
+				{Group: "apps", Version: "v1", Kind: "deployment"},
+			}}},
+			contents: deployment,
+			want:     true,
+		},
+		{
+			name: "kubernetes kind mismatch",
+			selectors: []Selector{{Type: "kubernetes", Subtypes: []SubType{
+				{Group: "apps", Version: "v1", Kind: "StatefulSet"},
+			}}},
+			contents: deployment,
+			want:     false,
+		},
+		{
+			name: "kubernetes group mismatch",
+			selectors: []Selector{{Type: "kubernetes", Subtypes: []SubType{
+				{Group: "batch", Kind: "Deployment"},
+			}}},
+			contents: deployment,
+			want:     false,
+		},
+		{
+			name: "kubernetes core group",
+			selectors: []Selector{{Type: "kubernetes", Subtypes: []SubType{
+				{Group: "core", Version: "v1", Kind: "Pod"},
+			}}},
+			contents: pod,
+			want:     true,
+		},
+		{
+			name: "kubernetes namespace",
+			selectors: []Selector{{Type: "kubernetes", Subtypes: []SubType{
+				{Kind: "Deployment", Namespace: "prod"},
+			}}},
+			contents: deployment,
+			want:     true,
+		},
+		{
+			name: "kubernetes default namespace",
+			selectors: []Selector{{Type: "kubernetes", Subtypes: []SubType{
+				{Kind: "Pod", Namespace: "kube-system"},
+			}}},
+			contents: pod,
+			want:     false,
+		},
+		{
+			name: "cloud service",
+			selectors: []Selector{{Type: "cloud", Subtypes: []SubType{
+				{Provider: "google", Service: "storage"},
+			}}},
+			contents: map[string]interface{}{
+				"google": map[string]interface{}{"storage": map[string]interface{}{}},
+			},
+			want: true,
+		},
+		{
+			name: "cloud service missing from input",
+			selectors: []Selector{{Type: "cloud", Subtypes: []SubType{
+				{Provider: "digitalocean", Service: "spaces"},
+			}}},
+			contents: map[string]interface{}{
+				"digitalocean": map[string]interface{}{"compute": map[string]interface{}{}},
+			},
+			want: false,
+		},
+		{
+			name: "cloud provider without service",
+			selectors: []Selector{{Type: "cloud", Subtypes: []SubType{
+				{Provider: "nifcloud"},
+			}}},
+			contents: map[string]interface{}{
+				"nifcloud": map[string]interface{}{"nas": map[string]interface{}{}},
+			},
+			want: true,
+		},
+		{
+			name: "cloud provider mismatch",
+			selectors: []Selector{{Type: "cloud", Subtypes: []SubType{
+				{Provider: "aws", Service: "s3"},
+			}}},
+			contents: map[string]interface{}{
+				"oracle": map[string]interface{}{"s3": map[string]interface{}{}},
+			},
+			want: false,
+		},
+		{
+			name:      "kubernetes selector for cloud input",
+			selectors: []Selector{{Type: "kubernetes"}},
+			contents: map[string]interface{}{
+				"github": map[string]interface{}{"repositories": []interface{}{}},
+			},
+			want: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metadata := &StaticMetadata{
+				InputOptions: InputOptions{Selectors: tt.selectors},
+			}
+			assert.Equal(t, tt.want, isPolicyApplicable(metadata, Input{Contents: tt.contents}))
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package rego
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/khulnasoft-lab/misscan/pkg/scan"
+)
+
+// Stats describes where the scanner has spent its time since it was created
+type Stats struct {
+	CompileDuration time.Duration    `json:"compile_duration"`
+	Modules         int              `json:"modules"`
+	Scans           int              `json:"scans"`
+	Inputs          int              `json:"inputs"`
+	Results         map[string]int   `json:"results"`
+	Namespaces      []NamespaceStats `json:"namespaces"`
+	Rules           []RuleStats      `json:"rules"`
+}
+
+// NamespaceStats totals the statistics of all rules in a namespace
+type NamespaceStats struct {
+	Namespace string         `json:"namespace"`
+	Duration  time.Duration  `json:"duration"`
+	Results   map[string]int `json:"results"`
+}
+
+type RuleStats struct {
+	Namespace   string         `json:"namespace"`
+	Rule        string         `json:"rule"`
+	Evaluations int            `json:"evaluations"`
+	Inputs      int            `json:"inputs"`
+	Duration    time.Duration  `json:"duration"`
+	MaxDuration time.Duration  `json:"max_duration"`
+	Results     map[string]int `json:"results"`
+}
+
+func (s Stats) WriteJSON(w io.Writer) error {
+	return json.NewEncoder(w).Encode(s)
+}
+
+type ruleKey struct {
+	namespace string
+	rule      string
+}
+
+// statsCollector accumulates statistics from concurrent evaluations
+type statsCollector struct {
+	mu      sync.Mutex
+	compile time.Duration
+	modules int
+	scans   int
+	inputs  int
+	results map[string]int
+	rules   map[ruleKey]*RuleStats
+}
+
+func newStatsCollector() *statsCollector {
+	return &statsCollector{
+		results: make(map[string]int),
+		rules:   make(map[ruleKey]*RuleStats),
+	}
+}
+
+func (c *statsCollector) recordCompile(duration time.Duration, modules int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.compile += duration
+	c.modules = modules
+}
+
+func (c *statsCollector) recordScan(inputs int, results scan.Results) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.scans++
+	c.inputs += inputs
+	for _, result := range results {
+		c.results[result.Status().String()]++
+	}
+}
+
+func (c *statsCollector) recordEvaluation(namespace, rule string, inputs int, duration time.Duration, results scan.Results) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := ruleKey{namespace: namespace, rule: rule}
+	stats, ok := c.rules[key]
+	if !ok {
+		stats = &RuleStats{
+			Namespace: namespace,
+			Rule:      rule,
+			Results:   make(map[string]int),
+		}
+		c.rules[key] = stats
+	}
+	stats.Evaluations++
+	stats.Inputs += inputs
+	stats.Duration += duration
+	if duration > stats.MaxDuration {
+		stats.MaxDuration = duration
+	}
+	for _, result := range results {
+		stats.Results[result.Status().String()]++
+	}
+}
+
+// snapshot copies the collected statistics, totalling them by namespace and ordering namespaces and rules from slowest
+// to fastest
+func (c *statsCollector) snapshot() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := Stats{
+		CompileDuration: c.compile,
+		Modules:         c.modules,
+		Scans:           c.scans,
+		Inputs:          c.inputs,
+		Results:         make(map[string]int, len(c.results)),
+	}
+	for status, count := range c.results {
+		stats.Results[status] = count
+	}
+	namespaces := make(map[string]*NamespaceStats)
+	for _, rule := range c.rules {
+		copied := *rule
+		copied.Results = make(map[string]int, len(rule.Results))
+		for status, count := range rule.Results {
+			copied.Results[status] = count
+		}
+		stats.Rules = append(stats.Rules, copied)
+
+		namespace, ok := namespaces[rule.Namespace]
+		if !ok {
+			namespace = &NamespaceStats{
+				Namespace: rule.Namespace,
+				Results:   make(map[string]int),
+			}
+			namespaces[rule.Namespace] = namespace
+		}
+		namespace.Duration += rule.Duration
+		for status, count := range rule.Results {
+			namespace.Results[status] += count
+		}
+	}
+	for _, namespace := range namespaces {
+		stats.Namespaces = append(stats.Namespaces, *namespace)
+	}
+	sort.Slice(stats.Namespaces, func(i, j int) bool {
+		if stats.Namespaces[i].Duration != stats.Namespaces[j].Duration {
+			return stats.Namespaces[i].Duration > stats.Namespaces[j].Duration
+		}
+		return stats.Namespaces[i].Namespace < stats.Namespaces[j].Namespace
+	})
+	sort.Slice(stats.Rules, func(i, j int) bool {
+		if stats.Rules[i].Duration != stats.Rules[j].Duration {
+			return stats.Rules[i].Duration > stats.Rules[j].Duration
+		}
+		if stats.Rules[i].Namespace != stats.Rules[j].Namespace {
+			return stats.Rules[i].Namespace < stats.Rules[j].Namespace
+		}
+		return stats.Rules[i].Rule < stats.Rules[j].Rule
+	})
+	return stats
+}
@@ -0,0 +1,121 @@
+package rego
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/khulnasoft-lab/misscan/pkg/scanners/options"
+	"github.com/khulnasoft-lab/misscan/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_RegoScanning_Stats(t *testing.T) {
+
+	srcFS := CreateFS(t, map[string]string{
+		"policies/test.rego": `
+package misscan.test
+
+deny {
+    input.evil
+}
+`,
+	})
+
+	statsWriter := bytes.NewBuffer(nil)
+	scanner := NewScanner(types.SourceJSON, options.ScannerWithStatsWriter(statsWriter))
+	require.NoError(
+		t,
+		scanner.LoadPolicies(false, false, srcFS, []string{"policies"}, nil),
+	)
+
+	_, err := scanner.ScanInput(context.TODO(),
+		Input{Path: "/evil.json", Contents: map[string]interface{}{"evil": true}},
+		Input{Path: "/good.json", Contents: map[string]interface{}{"evil": false}},
+	)
+	require.NoError(t, err)
+
+	stats := scanner.Stats()
+	assert.Positive(t, stats.CompileDuration)
+	assert.Equal(t, 1, stats.Modules)
+	assert.Equal(t, 1, stats.Scans)
+	assert.Equal(t, 2, stats.Inputs)
+	assert.Equal(t, map[string]int{"failed": 1, "passed": 1}, stats.Results)
+
+	require.Len(t, stats.Rules, 1)
+	rule := stats.Rules[0]
+	assert.Equal(t, "misscan.test", rule.Namespace)
+	assert.Equal(t, "deny", rule.Rule)
+	assert.Equal(t, 2, rule.Evaluations)
+	assert.Equal(t, 2, rule.Inputs)
+	assert.GreaterOrEqual(t, rule.Duration, rule.MaxDuration)
+	assert.Equal(t, map[string]int{"failed": 1, "passed": 1}, rule.Results)
+
+	var written Stats
+	require.NoError(t, json.Unmarshal(statsWriter.Bytes(), &written))
+	assert.Equal(t, stats.Scans, written.Scans)
+	assert.Equal(t, stats.Results, written.Results)
+}
+
+func Test_RegoScanning_NamespaceStats(t *testing.T) {
+
+	srcFS := CreateFS(t, map[string]string{
+		"policies/a.rego": `
+package misscan.a
+
+deny {
+    input.evil
+}
+
+warn {
+    input.suspicious
+}
+`,
+		"policies/b.rego": `
+package misscan.b
+
+deny {
+    input.evil
+}
+`,
+	})
+
+	statsWriter := bytes.NewBuffer(nil)
+	scanner := NewScanner(types.SourceJSON, options.ScannerWithStatsWriter(statsWriter))
+	require.NoError(
+		t,
+		scanner.LoadPolicies(false, false, srcFS, []string{"policies"}, nil),
+	)
+
+	_, err := scanner.ScanInput(context.TODO(),
+		Input{Path: "/evil.json", Contents: map[string]interface{}{"evil": true, "suspicious": true}},
+	)
+	require.NoError(t, err)
+
+	stats := scanner.Stats()
+	require.Len(t, stats.Namespaces, 2)
+	totals := make(map[string]NamespaceStats)
+	for _, namespace := range stats.Namespaces {
+		totals[namespace.Namespace] = namespace
+	}
+	require.Contains(t, totals, "misscan.a")
+	require.Contains(t, totals, "misscan.b")
+	assert.Equal(t, map[string]int{"failed": 2}, totals["misscan.a"].Results)
+	assert.Equal(t, map[string]int{"failed": 1}, totals["misscan.b"].Results)
+
+	var ruleDuration time.Duration
+	for _, rule := range stats.Rules {
+		if rule.Namespace == "misscan.a" {
+			ruleDuration += rule.Duration
+		}
+	}
+	assert.Equal(t, ruleDuration, totals["misscan.a"].Duration)
+	assert.GreaterOrEqual(t, stats.Namespaces[0].Duration, stats.Namespaces[1].Duration)
+
+	var written Stats
+	require.NoError(t, json.Unmarshal(statsWriter.Bytes(), &written))
+	assert.ElementsMatch(t, stats.Namespaces, written.Namespaces)
+}
@@ -11,8 +11,8 @@ import (
 	"github.com/open-policy-agent/opa/storage"
 )
 
-// initialise a store populated with OPA data files found in dataPaths
-func initStore(dataFS fs.FS, dataPaths, namespaces []string) (storage.Store, error) {
+// initialise a store populated with OPA data files found in dataPaths, plus any data loaded from bundles
+func initStore(dataFS fs.FS, dataPaths, namespaces []string, bundleData map[string]interface{}) (storage.Store, error) {
 	// FilteredPaths will recursively find all file paths that contain a valid document
 	// extension from the given list of data paths.
 	allDocumentPaths, _ := loader.FilteredPathsFS(dataFS, dataPaths, func(abspath string, info os.FileInfo, depth int) bool {
@@ -37,6 +37,10 @@ func initStore(dataFS fs.FS, dataPaths, namespaces []string) (storage.Store, err
 		return nil, fmt.Errorf("load documents: %w", err)
 	}
 
+	if err := mergeDocuments(documents.Documents, bundleData); err != nil {
+		return nil, fmt.Errorf("merge bundle data: %w", err)
+	}
+
 	// pass all namespaces so that rego rule can refer to namespaces as data.namespaces
 	documents.Documents["namespaces"] = namespaces
 
@@ -0,0 +1,211 @@
+package rego
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"time"
+
+	"github.com/khulnasoft-lab/misscan/pkg/rego/schemas"
+	"github.com/khulnasoft-lab/misscan/pkg/types"
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/open-policy-agent/opa/tester"
+)
+
+type TestStatus string
+
+const (
+	TestStatusPass  TestStatus = "PASS"
+	TestStatusFail  TestStatus = "FAIL"
+	TestStatusError TestStatus = "ERROR"
+	TestStatusSkip  TestStatus = "SKIP"
+)
+
+type TestResult struct {
+	Package  string        `json:"package"`
+	Name     string        `json:"name"`
+	Filename string        `json:"filename"`
+	Line     int           `json:"line"`
+	Status   TestStatus    `json:"status"`
+	Error    string        `json:"error,omitempty"`
+	Output   string        `json:"output,omitempty"`
+	Duration time.Duration `json:"duration"`
+}
+
+type TestReport struct {
+	Results  []TestResult    `json:"results"`
+	Coverage *CoverageReport `json:"coverage,omitempty"`
+}
+
+// Failed returns true if any test failed or errored
+func (r TestReport) Failed() bool {
+	for _, result := range r.Results {
+		if result.Status == TestStatusFail || result.Status == TestStatusError {
+			return true
+		}
+	}
+	return false
+}
+
+// TestRunner runs the test_* rules found in _test.rego files against policies compiled in the same way as the Scanner,
+// so tests have access to misscan builtins, schemas and the embedded libraries
+type TestRunner struct {
+	inputSchema       interface{}
+	dataDirs          []string
+	dataFS            fs.FS
+	embeddedLibraries bool
+	filter            string
+	coverage          bool
+	sandbox           Sandbox
+}
+
+func NewTestRunner(source types.Source) *TestRunner {
+	r := &TestRunner{
+		sandbox: DefaultSandbox(),
+	}
+	if schema, ok := schemas.SchemaMap[source]; ok && schema != schemas.None {
+		if err := json.Unmarshal([]byte(schema), &r.inputSchema); err != nil {
+			panic(err)
+		}
+	}
+	return r
+}
+
+func (r *TestRunner) SetDataDirs(dirs ...string) {
+	r.dataDirs = dirs
+}
+
+func (r *TestRunner) SetDataFilesystem(fs fs.FS) {
+	r.dataFS = fs
+}
+
+func (r *TestRunner) SetUseEmbeddedLibraries(b bool) {
+	r.embeddedLibraries = b
+}
+
+// SetFilter restricts the tests run to those whose name matches the given regular expression
+func (r *TestRunner) SetFilter(regex string) {
+	r.filter = regex
+}
+
+func (r *TestRunner) SetCoverageEnabled(b bool) {
+	r.coverage = b
+}
+
+// SetSandbox replaces the restrictions placed on the policies under test - see DefaultSandbox
+func (r *TestRunner) SetSandbox(sandbox Sandbox) {
+	r.sandbox = sandbox
+}
+
+// Run loads the policies and tests found in paths and runs every test
+func (r *TestRunner) Run(ctx context.Context, srcFS fs.FS, paths ...string) (*TestReport, error) {
+
+	modules := make(map[string]*ast.Module)
+	if r.embeddedLibraries {
+		libs, err := LoadEmbeddedLibraries()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load embedded rego libraries: %w", err)
+		}
+		for name, module := range libs {
+			modules[name] = module
+		}
+	}
+
+	loaded, err := LoadPoliciesAndTestsFromDirs(srcFS, paths...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load rego policies from %s: %w", paths, err)
+	}
+	for name, module := range loaded {
+		modules[name] = module
+	}
+
+	schemaSet, custom, err := BuildSchemaSetFromPolicies(modules, paths, srcFS)
+	if err != nil {
+		return nil, err
+	}
+	if !custom && r.inputSchema != nil {
+		schemaSet.Put(ast.MustParseRef("schema.input"), r.inputSchema)
+	}
+
+	uniq := make(map[string]struct{})
+	var namespaces []string
+	for _, module := range modules {
+		namespace := getModuleNamespace(module)
+		if _, ok := uniq[namespace]; ok {
+			continue
+		}
+		uniq[namespace] = struct{}{}
+		namespaces = append(namespaces, namespace)
+	}
+
+	dataFS := srcFS
+	if r.dataFS != nil {
+		dataFS = r.dataFS
+	}
+	store, err := initStore(dataFS, r.dataDirs, namespaces, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load data: %w", err)
+	}
+
+	txn, err := store.NewTransaction(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer store.Abort(ctx, txn)
+
+	runner := tester.NewRunner().
+		SetCompiler(newCompiler(schemaSet, r.sandbox)).
+		SetStore(store).
+		SetRuntime(addRuntimeValues(r.sandbox.AllowedEnv)).
+		SetModules(modules).
+		CapturePrintOutput(true).
+		Filter(r.filter)
+
+	var coverage *coverageTracer
+	if r.coverage {
+		coverage = newCoverageTracer()
+		runner.SetCoverageQueryTracer(coverage)
+	}
+
+	ch, err := runner.RunTests(ctx, txn)
+	if err != nil {
+		return nil, err
+	}
+
+	var report TestReport
+	for result := range ch {
+		report.Results = append(report.Results, convertTestResult(result))
+	}
+
+	if coverage != nil {
+		report.Coverage = NewCoverageReport(coverage.report(modules), modules)
+	}
+
+	return &report, nil
+}
+
+func convertTestResult(result *tester.Result) TestResult {
+	converted := TestResult{
+		Package:  result.Package,
+		Name:     result.Name,
+		Output:   string(result.Output),
+		Duration: result.Duration,
+	}
+	if result.Location != nil {
+		converted.Filename = result.Location.File
+		converted.Line = result.Location.Row
+	}
+	switch {
+	case result.Skip:
+		converted.Status = TestStatusSkip
+	case result.Error != nil:
+		converted.Status = TestStatusError
+		converted.Error = result.Error.Error()
+	case result.Fail:
+		converted.Status = TestStatusFail
+	default:
+		converted.Status = TestStatusPass
+	}
+	return converted
+}
@@ -0,0 +1,117 @@
+package rego
+
+import (
+	"context"
+	"testing"
+
+	"github.com/khulnasoft-lab/misscan/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_TestRunner(t *testing.T) {
+
+	srcFS := CreateFS(t, map[string]string{
+		"policies/test.rego": `# METADATA
+# custom:
+#   input:
+#     selector:
+#     - type: dockerfile
+package misscan.test
+
+deny[res] {
+    input.evil
+    res := result.new("evil", input)
+}
+
+conflict = "a" {
+    input.conflict
+}
+
+conflict = "b" {
+    input.conflict
+}
+`,
+		"policies/test_test.rego": `
+package misscan.test
+
+test_deny_evil {
+    r := deny with input as {"evil": true, "__misscan_metadata": {"startline": 3}}
+    count(r) == 1
+    r[_].startline == 3
+}
+
+test_allow_good {
+    count(deny) == 1 with input as {"evil": false}
+}
+
+test_conflict {
+    conflict == "a" with input as {"conflict": true}
+}
+
+todo_test_later {
+    false
+}
+`,
+	})
+
+	runner := NewTestRunner(types.SourceJSON)
+	runner.SetCoverageEnabled(true)
+	report, err := runner.Run(context.TODO(), srcFS, "policies")
+	require.NoError(t, err)
+
+	statuses := make(map[string]TestStatus)
+	for _, result := range report.Results {
+		statuses[result.Name] = result.Status
+		assert.Equal(t, "data.misscan.test", result.Package)
+		assert.Equal(t, "policies/test_test.rego", result.Filename)
+	}
+	assert.Equal(t, map[string]TestStatus{
+		"test_deny_evil":  TestStatusPass,
+		"test_allow_good": TestStatusFail,
+		"test_conflict":   TestStatusError,
+		"todo_test_later": TestStatusSkip,
+	}, statuses)
+	assert.True(t, report.Failed())
+
+	require.NotNil(t, report.Coverage)
+	var filenames []string
+	for _, module := range report.Coverage.Modules {
+		filenames = append(filenames, module.Filename)
+	}
+	assert.Contains(t, filenames, "policies/test.rego")
+}
+
+func Test_TestRunner_Filter(t *testing.T) {
+
+	srcFS := CreateFS(t, map[string]string{
+		"policies/test.rego": `
+package misscan.test
+
+deny {
+    input.evil
+}
+`,
+		"policies/test_test.rego": `
+package misscan.test
+
+test_deny {
+    deny with input as {"evil": true}
+}
+
+test_other {
+    false
+}
+`,
+	})
+
+	runner := NewTestRunner(types.SourceJSON)
+	runner.SetFilter("test_deny")
+	report, err := runner.Run(context.TODO(), srcFS, "policies")
+	require.NoError(t, err)
+
+	require.Len(t, report.Results, 1)
+	assert.Equal(t, TestStatusPass, report.Results[0].Status)
+	assert.False(t, report.Failed())
+	assert.Nil(t, report.Coverage)
+}
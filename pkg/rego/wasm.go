@@ -0,0 +1,313 @@
+package rego
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/open-policy-agent/opa/bundle"
+	"github.com/open-policy-agent/opa/compile"
+	"github.com/open-policy-agent/opa/rego"
+	"github.com/open-policy-agent/opa/resolver/wasm"
+	"github.com/open-policy-agent/opa/storage"
+)
+
+// wasmEngineLinked is set when the Wasm engine is linked into the build, which requires the opa_wasm build tag
+var wasmEngineLinked bool
+
+// wasmUnsupportedBuiltins are builtins which depend on context that the interpreter provides but the Wasm engine does
+// not, so policies calling them are always evaluated by the interpreter
+var wasmUnsupportedBuiltins = map[string]string{
+	"opa.runtime": "runtime values are not passed to the Wasm engine",
+	"trace":       "notes are not recorded by the Wasm engine",
+}
+
+// Engine is the evaluator used for the rules of a policy
+type Engine string
+
+const (
+	EngineInterpreter Engine = "rego"
+	EngineWasm        Engine = "wasm"
+)
+
+// PolicyEngine records which engine evaluates the enforced rules of a policy. When the Wasm backend is enabled but a
+// policy falls back to the interpreter, Reason explains why.
+type PolicyEngine struct {
+	Namespace string `json:"namespace"`
+	Engine    Engine `json:"engine"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+// SetWasmEnabled compiles the enforced rules of the loaded policies to a single Wasm module when policies are loaded,
+// and evaluates them through the Wasm engine. Policies which cannot be compiled are evaluated by the interpreter, as are all policies while
+// tracing, coverage or explanations are enabled.
+func (s *Scanner) SetWasmEnabled(b bool) {
+	s.wasm = b
+}
+
+// PolicyEngines reports the engine used for each loaded policy, ordered by namespace
+func (s *Scanner) PolicyEngines() []PolicyEngine {
+	s.reload.mu.RLock()
+	defer s.reload.mu.RUnlock()
+
+	if s.queries == nil {
+		return nil
+	}
+	s.queries.RLock()
+	defer s.queries.RUnlock()
+
+	engines := make([]PolicyEngine, 0, len(s.queries.engines))
+	for _, engine := range s.queries.engines {
+		engines = append(engines, engine)
+	}
+	sort.Slice(engines, func(i, j int) bool {
+		return engines[i].Namespace < engines[j].Namespace
+	})
+	return engines
+}
+
+// prepareWasmQueries compiles the enforced rules of the given policies, keyed by namespace, into a single Wasm module
+// with an entrypoint for each rule, and prepares a query for each rule which is resolved by its entrypoint. If a policy
+// cannot be compiled, the whole policy is evaluated by the interpreter so that its rules are consistent with each other.
+func (s *Scanner) prepareWasmQueries(ctx context.Context, policies map[string][]string) {
+	engines := make(map[string]PolicyEngine, len(policies))
+	for namespace := range policies {
+		engines[namespace] = PolicyEngine{Namespace: namespace, Engine: EngineInterpreter}
+	}
+	defer func() {
+		s.queries.Lock()
+		for namespace, engine := range engines {
+			s.queries.engines[namespace] = engine
+		}
+		s.queries.Unlock()
+	}()
+
+	if !s.wasm {
+		return
+	}
+	fallback := func(namespace string, reason string) {
+		engines[namespace] = PolicyEngine{Namespace: namespace, Engine: EngineInterpreter, Reason: reason}
+		s.debug.Log("Policy %s falls back to the interpreter: %s", namespace, reason)
+	}
+	if !wasmEngineLinked {
+		for namespace := range policies {
+			engines[namespace] = PolicyEngine{
+				Namespace: namespace,
+				Engine:    EngineInterpreter,
+				Reason:    "the Wasm engine is not linked into this build, see the opa_wasm build tag",
+			}
+		}
+		return
+	}
+
+	candidates := make(map[string][]string)
+	for namespace, rules := range policies {
+		if rule, builtin, ok := s.unsupportedWasmRule(namespace, rules); ok {
+			fallback(namespace, fmt.Sprintf("%s calls %s: %s", rule, builtin, wasmUnsupportedBuiltins[builtin]))
+			continue
+		}
+		candidates[namespace] = rules
+	}
+	if len(candidates) == 0 {
+		return
+	}
+
+	module, err := s.compileWasm(ctx, candidates)
+	if err != nil {
+		// find the policies which cannot be compiled, and compile the others without them
+		for namespace, rules := range candidates {
+			if _, err := s.compileWasm(ctx, map[string][]string{namespace: rules}); err != nil {
+				fallback(namespace, fmt.Sprintf("compile to Wasm: %s", err))
+				delete(candidates, namespace)
+			}
+		}
+		if len(candidates) == 0 {
+			return
+		}
+		if module, err = s.compileWasm(ctx, candidates); err != nil {
+			for namespace := range candidates {
+				fallback(namespace, fmt.Sprintf("compile to Wasm: %s", err))
+			}
+			return
+		}
+	}
+
+	refs := wasmEntrypointRefs(candidates)
+	data, err := storage.ReadOne(ctx, s.store, storage.Path{})
+	if err == nil {
+		var resolver *wasm.Resolver
+		if resolver, err = wasm.New(refs, module, data); err == nil {
+			s.queries.Lock()
+			s.queries.resolver = resolver
+			s.queries.Unlock()
+		}
+	}
+	if err != nil {
+		for namespace := range candidates {
+			fallback(namespace, fmt.Sprintf("load Wasm module: %s", err))
+		}
+		return
+	}
+
+	for namespace, rules := range candidates {
+		prepared := make(map[string]rego.PreparedEvalQuery, len(rules))
+		for _, rule := range rules {
+			query := ruleQuery(namespace, rule)
+			wasmQuery, err := s.prepareWasmQuery(ctx, query)
+			if err != nil {
+				fallback(namespace, fmt.Sprintf("prepare %s for Wasm: %s", query, err))
+				prepared = nil
+				break
+			}
+			prepared[query] = wasmQuery
+		}
+		if prepared == nil {
+			continue
+		}
+		s.queries.Lock()
+		for query, wasmQuery := range prepared {
+			s.queries.wasm[query] = wasmQuery
+		}
+		s.queries.Unlock()
+		engines[namespace] = PolicyEngine{Namespace: namespace, Engine: EngineWasm}
+	}
+}
+
+// unsupportedWasmRule finds a rule of a policy which calls a builtin that the Wasm engine does not support
+func (s *Scanner) unsupportedWasmRule(namespace string, rules []string) (string, string, bool) {
+	for _, rule := range rules {
+		if builtin, ok := s.unsupportedWasmBuiltin(namespace, rule); ok {
+			return rule, builtin, true
+		}
+	}
+	return "", "", false
+}
+
+// wasmEntrypoints returns the entrypoint of each rule of the policies, in the <package>/<rule> form used by OPA
+func wasmEntrypoints(policies map[string][]string) []string {
+	var entrypoints []string
+	for namespace, rules := range policies {
+		for _, rule := range rules {
+			entrypoints = append(entrypoints, strings.ReplaceAll(namespace, ".", "/")+"/"+rule)
+		}
+	}
+	sort.Strings(entrypoints)
+	return entrypoints
+}
+
+func wasmEntrypointRefs(policies map[string][]string) []ast.Ref {
+	var refs []ast.Ref
+	for namespace, rules := range policies {
+		for _, rule := range rules {
+			refs = append(refs, ast.MustParseRef(ruleQuery(namespace, rule)))
+		}
+	}
+	return refs
+}
+
+// compileWasm compiles the loaded policies into a Wasm module with an entrypoint for each rule of the given policies
+func (s *Scanner) compileWasm(ctx context.Context, policies map[string][]string) ([]byte, error) {
+	filenames := make([]string, 0, len(s.policies))
+	for filename := range s.policies {
+		filenames = append(filenames, filename)
+	}
+	sort.Strings(filenames)
+
+	b := &bundle.Bundle{}
+	for _, filename := range filenames {
+		b.Modules = append(b.Modules, bundle.ModuleFile{
+			URL:    filename,
+			Path:   filename,
+			Parsed: s.policies[filename].Copy(), // building the bundle formats its modules
+		})
+	}
+
+	compiler := compile.New().
+		WithTarget(compile.TargetWasm).
+		WithEntrypoints(wasmEntrypoints(policies)...).
+		WithPruneUnused(true).
+		WithCapabilities(s.sandbox.capabilities()).
+		WithBundle(b)
+	if err := compiler.Build(ctx); err != nil {
+		return nil, err
+	}
+	modules := compiler.Bundle().WasmModules
+	if len(modules) != 1 {
+		return nil, fmt.Errorf("expected a single Wasm module, found %d", len(modules))
+	}
+	return modules[0].Raw, nil
+}
+
+// unsupportedWasmBuiltin finds a call to a builtin which the Wasm engine does not support in a rule, or in any rule or
+// function it depends on
+func (s *Scanner) unsupportedWasmBuiltin(namespace string, rule string) (string, bool) {
+	ref, err := ast.ParseRef(ruleQuery(namespace, rule))
+	if err != nil {
+		return "", false
+	}
+
+	var found string
+	visited := make(map[*ast.Rule]struct{})
+	queue := s.compiler.GetRulesExact(ref)
+	for len(queue) > 0 && found == "" {
+		current := queue[0]
+		queue = queue[1:]
+		if _, ok := visited[current]; ok {
+			continue
+		}
+		visited[current] = struct{}{}
+
+		ast.WalkExprs(current, func(expr *ast.Expr) bool {
+			if !expr.IsCall() {
+				return false
+			}
+			if _, ok := wasmUnsupportedBuiltins[expr.Operator().String()]; ok {
+				found = expr.Operator().String()
+				return true
+			}
+			return false
+		})
+
+		for dependency := range s.compiler.Graph.Dependencies(current) {
+			if dependent, ok := dependency.(*ast.Rule); ok {
+				queue = append(queue, dependent)
+			}
+		}
+	}
+	return found, found != ""
+}
+
+// prepareWasmQuery prepares a rule query which is resolved by the entrypoint of the rule in the Wasm module
+func (s *Scanner) prepareWasmQuery(ctx context.Context, query string) (rego.PreparedEvalQuery, error) {
+	regoOptions := []func(*rego.Rego){
+		rego.Query(query),
+		rego.Compiler(s.compiler),
+		rego.Store(s.store),
+		rego.Runtime(s.runtimeValues),
+		rego.Resolver(ast.MustParseRef(query), s.queries.resolver),
+	}
+	if s.inputSchema != nil {
+		regoOptions = append(regoOptions, rego.Schemas(s.inputSchemaSet()))
+	}
+	return rego.New(regoOptions...).PrepareForEval(ctx)
+}
+
+// wasmQuery returns the query compiled to Wasm, if the policy it belongs to is evaluated by the Wasm engine
+func (c *queryCache) wasmQuery(query string) (rego.PreparedEvalQuery, bool) {
+	c.RLock()
+	defer c.RUnlock()
+	prepared, ok := c.wasm[query]
+	return prepared, ok
+}
+
+// close releases the Wasm module, once no scan can use the queries any more
+func (c *queryCache) close() {
+	c.Lock()
+	defer c.Unlock()
+	if c.resolver != nil {
+		c.resolver.Close()
+		c.resolver = nil
+	}
+}
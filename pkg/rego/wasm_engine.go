@@ -0,0 +1,11 @@
+//go:build opa_wasm
+
+package rego
+
+// The Wasm engine runs modules through wasmtime, which requires cgo, so it is only linked into builds with the opa_wasm
+// build tag - the same tag OPA uses for its own Wasm support.
+import _ "github.com/open-policy-agent/opa/features/wasm"
+
+func init() {
+	wasmEngineLinked = true
+}
@@ -0,0 +1,78 @@
+//go:build opa_wasm
+
+package rego
+
+import (
+	"context"
+	"testing"
+
+	"github.com/khulnasoft-lab/misscan/pkg/scan"
+	"github.com/khulnasoft-lab/misscan/pkg/scanners/options"
+	"github.com/khulnasoft-lab/misscan/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The tests in this file need the Wasm engine, which requires cgo. CI runs them on Linux with `make test-wasm`, which
+// is `go test -tags opa_wasm ./pkg/rego`.
+
+func Test_RegoScanning_Wasm(t *testing.T) {
+	files := wasmTestFS(t)
+	files["policies/fallback.rego"] = `
+package misscan.fallback
+
+deny[res] {
+    traced
+    res := "fallback"
+}
+
+traced {
+    input.evil
+    trace("evil input")
+}
+`
+	srcFS := CreateFS(t, files)
+
+	input := Input{
+		Path:     "/evil.json",
+		Contents: map[string]interface{}{"evil": true, "ports": []interface{}{22}, "suspicious": true},
+	}
+
+	interpreter := NewScanner(types.SourceJSON)
+	require.NoError(t, interpreter.LoadPolicies(false, false, srcFS, []string{"policies"}, nil))
+	expected, err := interpreter.ScanInput(context.TODO(), input)
+	require.NoError(t, err)
+
+	scanner := NewScanner(types.SourceJSON, options.ScannerWithWasm(true))
+	require.NoError(t, scanner.LoadPolicies(false, false, srcFS, []string{"policies"}, nil))
+
+	engines := make(map[string]PolicyEngine)
+	for _, engine := range scanner.PolicyEngines() {
+		engines[engine.Namespace] = engine
+	}
+	assert.Equal(t, EngineWasm, engines["misscan.test"].Engine)
+	assert.Equal(t, EngineWasm, engines["misscan.other"].Engine)
+	assert.Equal(t, EngineInterpreter, engines["misscan.fallback"].Engine)
+	assert.Equal(t, "deny calls trace: notes are not recorded by the Wasm engine", engines["misscan.fallback"].Reason)
+
+	// the rules of every policy evaluated by the Wasm engine are entrypoints of a single module
+	require.NotNil(t, scanner.queries.resolver)
+	var entrypoints []string
+	for _, ref := range scanner.queries.resolver.Entrypoints() {
+		entrypoints = append(entrypoints, ref.String())
+	}
+	assert.ElementsMatch(t, []string{"data.misscan.test.deny", "data.misscan.other.deny", "data.misscan.other.warn"}, entrypoints)
+
+	results, err := scanner.ScanInput(context.TODO(), input)
+	require.NoError(t, err)
+
+	summarise := func(results scan.Results) map[string]scan.Status {
+		summary := make(map[string]scan.Status)
+		for _, result := range results {
+			summary[result.RegoNamespace()+"."+result.RegoRule()+": "+result.Description()] = result.Status()
+		}
+		return summary
+	}
+	assert.Equal(t, summarise(expected), summarise(results))
+	assert.Len(t, results.GetFailed(), 4)
+}
@@ -0,0 +1,76 @@
+package rego
+
+import (
+	"context"
+	"testing"
+
+	"github.com/khulnasoft-lab/misscan/pkg/scanners/options"
+	"github.com/khulnasoft-lab/misscan/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func wasmTestFS(t *testing.T) map[string]string {
+	return map[string]string{
+		"policies/test.rego": `
+# METADATA
+# custom:
+#   avd_id: AVD-TEST-0001
+package misscan.test
+
+deny[res] {
+    input.evil
+    res := result.new("evil", input)
+}
+`,
+		"policies/other.rego": `
+package misscan.other
+
+deny[res] {
+    input.ports[_] == 22
+    res := sprintf("port %d is open", [22])
+}
+
+warn {
+    input.suspicious
+}
+`,
+	}
+}
+
+func Test_RegoScanning_WasmDisabled(t *testing.T) {
+	srcFS := CreateFS(t, wasmTestFS(t))
+
+	scanner := NewScanner(types.SourceJSON)
+	require.NoError(t, scanner.LoadPolicies(false, false, srcFS, []string{"policies"}, nil))
+
+	assert.Equal(t, []PolicyEngine{
+		{Namespace: "misscan.other", Engine: EngineInterpreter},
+		{Namespace: "misscan.test", Engine: EngineInterpreter},
+	}, scanner.PolicyEngines())
+}
+
+func Test_RegoScanning_WasmWithoutEngine(t *testing.T) {
+	if wasmEngineLinked {
+		t.Skip("the Wasm engine is linked into this build")
+	}
+
+	srcFS := CreateFS(t, wasmTestFS(t))
+
+	scanner := NewScanner(types.SourceJSON, options.ScannerWithWasm(true))
+	require.NoError(t, scanner.LoadPolicies(false, false, srcFS, []string{"policies"}, nil))
+
+	engines := scanner.PolicyEngines()
+	require.Len(t, engines, 2)
+	for _, engine := range engines {
+		assert.Equal(t, EngineInterpreter, engine.Engine)
+		assert.Contains(t, engine.Reason, "opa_wasm")
+	}
+
+	results, err := scanner.ScanInput(context.TODO(), Input{
+		Path:     "/evil.json",
+		Contents: map[string]interface{}{"evil": true, "ports": []interface{}{22}},
+	})
+	require.NoError(t, err)
+	assert.Len(t, results.GetFailed(), 2)
+}
@@ -0,0 +1,91 @@
+package scan
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+
+	"github.com/khulnasoft-lab/misscan/pkg/severity"
+)
+
+const checkstyleVersion = "4.3"
+
+// CheckstyleWriter writes failed results as Checkstyle XML, grouped by file in the order the files are first seen
+type CheckstyleWriter struct{}
+
+func NewCheckstyleWriter() *CheckstyleWriter {
+	return &CheckstyleWriter{}
+}
+
+type checkstyleReport struct {
+	XMLName xml.Name         `xml:"checkstyle"`
+	Version string           `xml:"version,attr"`
+	Files   []checkstyleFile `xml:"file"`
+}
+
+type checkstyleFile struct {
+	Name   string            `xml:"name,attr"`
+	Errors []checkstyleError `xml:"error"`
+}
+
+type checkstyleError struct {
+	Line     int    `xml:"line,attr"`
+	Column   int    `xml:"column,attr,omitempty"`
+	Severity string `xml:"severity,attr"`
+	Message  string `xml:"message,attr"`
+	Source   string `xml:"source,attr"`
+}
+
+// Write encodes the failed results as indented Checkstyle XML
+func (w *CheckstyleWriter) Write(out io.Writer, results Results) error {
+	report := checkstyleReport{Version: checkstyleVersion}
+	files := make(map[string]int)
+
+	for i := range results {
+		result := &results[i]
+		if result.Status() != StatusFailed {
+			continue
+		}
+		rng := result.Range()
+		filename := reportPath(rng.GetFilename())
+		index, ok := files[filename]
+		if !ok {
+			index = len(report.Files)
+			files[filename] = index
+			report.Files = append(report.Files, checkstyleFile{Name: filename})
+		}
+
+		message := reportMessage(result)
+		if reference := result.Metadata().Reference(); reference != "" {
+			message = fmt.Sprintf("%s (%s)", message, reference)
+		}
+		report.Files[index].Errors = append(report.Files[index].Errors, checkstyleError{
+			Line:     rng.GetStartLine(),
+			Severity: checkstyleSeverity(result.Severity()),
+			Message:  message,
+			Source:   reportRuleID(result),
+		})
+	}
+
+	if _, err := io.WriteString(out, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(out)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		return err
+	}
+	_, err := io.WriteString(out, "\n")
+	return err
+}
+
+func checkstyleSeverity(sev severity.Severity) string {
+	switch sev {
+	case severity.Medium:
+		return "warning"
+	case severity.Low:
+		return "info"
+	default:
+		return "error"
+	}
+}
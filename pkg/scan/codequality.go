@@ -0,0 +1,116 @@
+package scan
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"strings"
+
+	"github.com/khulnasoft-lab/misscan/pkg/severity"
+)
+
+// CodeQualityWriter writes failed results as a GitLab Code Quality report. Each issue has a fingerprint which does not
+// depend on line numbers, so that GitLab can track an issue across changes to the surrounding file. The fingerprint
+// extends Result.Fingerprint with the resource the result was found in and its description, so that several results of
+// a rule in the same root resource and snippet are told apart without depending on their order. Results which are
+// identical in all of these are reported once, at the first line any of them were found on.
+type CodeQualityWriter struct{}
+
+func NewCodeQualityWriter() *CodeQualityWriter {
+	return &CodeQualityWriter{}
+}
+
+type codeQualityIssue struct {
+	Description string              `json:"description"`
+	CheckName   string              `json:"check_name"`
+	Fingerprint string              `json:"fingerprint"`
+	Severity    string              `json:"severity"`
+	Location    codeQualityLocation `json:"location"`
+}
+
+type codeQualityLocation struct {
+	Path  string           `json:"path"`
+	Lines codeQualityLines `json:"lines"`
+}
+
+type codeQualityLines struct {
+	Begin int `json:"begin"`
+	End   int `json:"end,omitempty"`
+}
+
+// Write encodes the failed results as an indented JSON array of issues
+func (w *CodeQualityWriter) Write(out io.Writer, results Results) error {
+	issues := []codeQualityIssue{}
+	indexes := make(map[string]int)
+	fingerprints := newFingerprinter()
+
+	for i := range results {
+		result := &results[i]
+		if result.Status() != StatusFailed {
+			continue
+		}
+		rng := result.Range()
+
+		fingerprint := codeQualityFingerprint(fingerprints.fingerprint(result), result)
+		issue := codeQualityIssue{
+			Description: reportMessage(result),
+			CheckName:   reportRuleID(result),
+			Fingerprint: fingerprint,
+			Severity:    codeQualitySeverity(result.Severity()),
+			Location: codeQualityLocation{
+				Path: reportPath(rng.GetFilename()),
+				Lines: codeQualityLines{
+					Begin: max(rng.GetStartLine(), 1),
+					End:   rng.GetEndLine(),
+				},
+			},
+		}
+
+		if index, ok := indexes[fingerprint]; ok {
+			if codeQualityLinesBefore(issue.Location.Lines, issues[index].Location.Lines) {
+				issues[index] = issue
+			}
+			continue
+		}
+		indexes[fingerprint] = len(issues)
+		issues = append(issues, issue)
+	}
+
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(issues)
+}
+
+// codeQualityFingerprint extends the fingerprint of a result with the resource it was found in and its description
+func codeQualityFingerprint(fingerprint string, result *Result) string {
+	sum := sha256.Sum256([]byte(strings.Join([]string{
+		fingerprint,
+		result.Metadata().Reference(),
+		result.Description(),
+	}, "\x00")))
+	return hex.EncodeToString(sum[:])
+}
+
+// codeQualityLinesBefore reports whether a starts before b, or ends first if they start on the same line
+func codeQualityLinesBefore(a, b codeQualityLines) bool {
+	if a.Begin != b.Begin {
+		return a.Begin < b.Begin
+	}
+	return a.End < b.End
+}
+
+func codeQualitySeverity(sev severity.Severity) string {
+	switch sev {
+	case severity.Critical:
+		return "blocker"
+	case severity.High:
+		return "critical"
+	case severity.Medium:
+		return "major"
+	case severity.Low:
+		return "minor"
+	default:
+		return "info"
+	}
+}
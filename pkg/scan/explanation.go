@@ -0,0 +1,57 @@
+package scan
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Explanation is the chain of expressions which evaluated to true for a rule to produce a result
+type Explanation struct {
+	Rule  string            `json:"rule"`
+	Steps []ExplanationStep `json:"steps"`
+}
+
+// ExplanationStep is a single expression in the body of the rule, with the values it referred to
+type ExplanationStep struct {
+	Expression string             `json:"expression"`
+	Filename   string             `json:"filename,omitempty"`
+	Line       int                `json:"line,omitempty"`
+	Values     []ExplanationValue `json:"values,omitempty"`
+}
+
+// ExplanationValue is the value of a reference or variable when the rule produced the result
+type ExplanationValue struct {
+	Ref   string      `json:"ref"`
+	Value interface{} `json:"value"`
+}
+
+const maxExplanationValueLength = 80
+
+// String renders the explanation as indented text, one expression per line followed by the values it referred to
+func (e Explanation) String() string {
+	var sb strings.Builder
+	_, _ = fmt.Fprintf(&sb, "%s:\n", e.Rule)
+	for _, step := range e.Steps {
+		if step.Filename != "" {
+			_, _ = fmt.Fprintf(&sb, "  %s:%d: %s\n", step.Filename, step.Line, step.Expression)
+		} else {
+			_, _ = fmt.Fprintf(&sb, "  %s\n", step.Expression)
+		}
+		for _, value := range step.Values {
+			_, _ = fmt.Fprintf(&sb, "      %s = %s\n", value.Ref, formatExplanationValue(value.Value))
+		}
+	}
+	return sb.String()
+}
+
+func formatExplanationValue(value interface{}) string {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	if len(encoded) > maxExplanationValueLength {
+		return string(encoded[:maxExplanationValueLength-3]) + "..."
+	}
+	return string(encoded)
+}
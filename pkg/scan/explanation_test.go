@@ -0,0 +1,39 @@
+package scan_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/khulnasoft-lab/misscan/pkg/scan"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Explanation_String(t *testing.T) {
+	explanation := scan.Explanation{
+		Rule: "data.builtin.dockerfile.DS002.deny",
+		Steps: []scan.ExplanationStep{
+			{
+				Expression: `input.Stages[1].Commands[3].Value[0] == "root"`,
+				Filename:   "policies/DS002.rego",
+				Line:       12,
+				Values: []scan.ExplanationValue{
+					{Ref: "input.Stages[1].Commands[3].Value[0]", Value: "root"},
+				},
+			},
+			{
+				Expression: "long := input.Stages",
+				Values: []scan.ExplanationValue{
+					{Ref: "long", Value: strings.Repeat("x", 100)},
+				},
+			},
+		},
+	}
+
+	expected := `data.builtin.dockerfile.DS002.deny:
+  policies/DS002.rego:12: input.Stages[1].Commands[3].Value[0] == "root"
+      input.Stages[1].Commands[3].Value[0] = "root"
+  long := input.Stages
+      long = "` + strings.Repeat("x", 76) + `...
+`
+	assert.Equal(t, expected, explanation.String())
+}
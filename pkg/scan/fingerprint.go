@@ -0,0 +1,99 @@
+package scan
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+)
+
+// FingerprintVersion is the version of the algorithm used by Result.Fingerprint. It is part of every fingerprint and
+// is incremented whenever a change to the algorithm would change the fingerprint of an existing finding.
+//
+// Version 1 is the hex encoded SHA-256 of the following fields, each terminated by a NUL byte:
+//
+//  1. the AVD ID of the rule, or if it has none its long ID, or otherwise its rego namespace and rule
+//  2. the reference of the root resource, e.g. module.bucket for a resource created by a module
+//  3. the slash separated path of the file the result was found in, relative to the root of the scan
+//  4. the hex encoded SHA-256 of the normalised snippet, or nothing if the file cannot be read
+//
+// The snippet is normalised by trimming each line, collapsing runs of whitespace to a single space and removing empty
+// lines. Line numbers are never part of a fingerprint, so a finding keeps its fingerprint when lines are added or
+// removed above it.
+const FingerprintVersion = 1
+
+// Fingerprint identifies a finding across scans, so that it can be tracked as the same finding while the file it was
+// found in changes. Fingerprints have the form v<version>:<hash>, see FingerprintVersion.
+func (r *Result) Fingerprint() string {
+	return newFingerprinter().fingerprint(r)
+}
+
+// fingerprinter computes fingerprints, reading each file once however many results were found in it
+type fingerprinter struct {
+	files map[string][]string
+}
+
+func newFingerprinter() *fingerprinter {
+	return &fingerprinter{
+		files: make(map[string][]string),
+	}
+}
+
+func (f *fingerprinter) fingerprint(r *Result) string {
+	var b strings.Builder
+	for _, field := range []string{
+		reportRuleID(r),
+		r.metadata.Root().Reference(),
+		reportPath(r.Range().GetFilename()),
+		f.snippetHash(r),
+	} {
+		b.WriteString(field)
+		b.WriteByte(0)
+	}
+	sum := sha256.Sum256([]byte(b.String()))
+	return fmt.Sprintf("v%d:%s", FingerprintVersion, hex.EncodeToString(sum[:]))
+}
+
+// snippetHash hashes the normalised lines of the result's range, or returns an empty string if they are unavailable
+func (f *fingerprinter) snippetHash(r *Result) string {
+	rng := r.Range()
+	if rng.GetStartLine() == 0 || validateRange(rng) != nil {
+		return ""
+	}
+	lines, ok := f.lines(r)
+	if !ok || rng.GetEndLine() > len(lines) {
+		return ""
+	}
+
+	normalised := make([]string, 0, rng.LineCount())
+	for _, line := range lines[rng.GetStartLine()-1 : rng.GetEndLine()] {
+		if line = strings.Join(strings.Fields(line), " "); line != "" {
+			normalised = append(normalised, line)
+		}
+	}
+	sum := sha256.Sum256([]byte(strings.Join(normalised, "\n")))
+	return hex.EncodeToString(sum[:])
+}
+
+// lines returns the lines of the file a result was found in, or false if the file cannot be read
+func (f *fingerprinter) lines(r *Result) ([]string, bool) {
+	rng := r.Range()
+	srcFS := rng.GetFS()
+	if srcFS == nil {
+		return nil, false
+	}
+	key := rng.GetFSKey() + "\x00" + r.fsPath
+	if lines, ok := f.files[key]; ok {
+		return lines, lines != nil
+	}
+	content, err := fs.ReadFile(srcFS, strings.TrimPrefix(filepath.ToSlash(r.fsPath), "/"))
+	if err != nil {
+		f.files[key] = nil
+		return nil, false
+	}
+	lines := strings.Split(string(content), "\n")
+	f.files[key] = lines
+	return lines, true
+}
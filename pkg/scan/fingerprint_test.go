@@ -0,0 +1,125 @@
+package scan_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"testing"
+
+	"github.com/khulnasoft-lab/misscan/pkg/scan"
+	"github.com/khulnasoft-lab/misscan/pkg/types"
+	"github.com/liamg/memoryfs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Fingerprint(t *testing.T) {
+	const bucket = `resource "aws_s3_bucket" "example" {
+  bucket = "example"
+  acl    = "public-read"
+}
+`
+	fingerprint := func(t *testing.T, content string, line int, rule scan.Rule, root string) string {
+		fsys := memoryfs.New()
+		require.NoError(t, fsys.WriteFile("main.tf", []byte(content), 0o600))
+
+		metadata := types.NewMetadata(types.NewRange("main.tf", line, line, "", fsys), "aws_s3_bucket.example")
+		if root != "" {
+			metadata = metadata.WithParent(types.NewMetadata(types.NewRange("main.tf", 1, 1, "", fsys), root))
+		}
+		var results scan.Results
+		results.Add("Bucket has a public ACL: 'public-read'.", metadata)
+		results.SetRule(rule)
+		return results[0].Fingerprint()
+	}
+	rule := scan.Rule{AVDID: "AVD-AWS-0092"}
+	original := fingerprint(t, bucket, 3, rule, "")
+
+	assert.Regexp(t, fmt.Sprintf("^v%d:[0-9a-f]{64}$", scan.FingerprintVersion), original)
+	assert.Equal(t, original, fingerprint(t, bucket, 3, rule, ""), "fingerprints are deterministic")
+
+	t.Run("lines added above", func(t *testing.T) {
+		assert.Equal(t, original, fingerprint(t, "# buckets\n\n"+bucket, 5, rule, ""))
+	})
+
+	t.Run("whitespace changes", func(t *testing.T) {
+		reformatted := `resource "aws_s3_bucket" "example" {
+  bucket = "example"
+      acl =   "public-read"   
+}
+`
+		assert.Equal(t, original, fingerprint(t, reformatted, 3, rule, ""))
+	})
+
+	t.Run("snippet changes", func(t *testing.T) {
+		changed := `resource "aws_s3_bucket" "example" {
+  bucket = "example"
+  acl    = "public-read-write"
+}
+`
+		assert.NotEqual(t, original, fingerprint(t, changed, 3, rule, ""))
+	})
+
+	t.Run("different rule", func(t *testing.T) {
+		assert.NotEqual(t, original, fingerprint(t, bucket, 3, scan.Rule{AVDID: "AVD-AWS-0093"}, ""))
+	})
+
+	t.Run("different root resource", func(t *testing.T) {
+		assert.NotEqual(t, original, fingerprint(t, bucket, 3, rule, "module.bucket"))
+	})
+
+	t.Run("flattened", func(t *testing.T) {
+		results := sarifTestResults(t)
+		assert.Equal(t, results[0].Fingerprint(), results[0].Flatten().Fingerprint)
+		assert.Equal(t, results[0].Fingerprint(), results[1].Fingerprint(), "suppression does not change a fingerprint")
+		assert.NotEqual(t, results[0].Fingerprint(), results[2].Fingerprint())
+	})
+}
+
+type countingFS struct {
+	fs.FS
+	opened map[string]int
+}
+
+func (c *countingFS) Open(name string) (fs.File, error) {
+	c.opened[name]++
+	return c.FS.Open(name)
+}
+
+func Test_Fingerprint_ReadsEachFileOnce(t *testing.T) {
+	files := memoryfs.New()
+	require.NoError(t, files.WriteFile("main.tf", []byte("resource \"a\" \"b\" {\n  x = 1\n  y = 2\n}\n"), 0o600))
+	fsys := &countingFS{FS: files, opened: make(map[string]int)}
+
+	var results scan.Results
+	for line := 1; line <= 3; line++ {
+		results.Add("finding", types.NewMetadata(types.NewRange("main.tf", line, line, "", fsys), "a.b"))
+	}
+	results.SetRule(scan.Rule{AVDID: "AVD-TEST-0001"})
+
+	flat := results.Flatten()
+	assert.Equal(t, 1, fsys.opened["main.tf"])
+	for i := range results {
+		assert.Equal(t, results[i].Fingerprint(), flat[i].Fingerprint)
+	}
+	assert.NotEqual(t, flat[0].Fingerprint, flat[1].Fingerprint)
+}
+
+func Test_Flatten_FingerprintsOnlyFailedResults(t *testing.T) {
+	files := memoryfs.New()
+	require.NoError(t, files.WriteFile("main.tf", []byte("resource \"a\" \"b\" {\n  x = 1\n}\n"), 0o600))
+	fsys := &countingFS{FS: files, opened: make(map[string]int)}
+
+	var results scan.Results
+	results.AddPassed(types.NewMetadata(types.NewRange("main.tf", 1, 3, "", fsys), "a.b"))
+	results.SetRule(scan.Rule{AVDID: "AVD-TEST-0001"})
+
+	flat := results.Flatten()
+	require.Len(t, flat, 1)
+	assert.Empty(t, flat[0].Fingerprint)
+	assert.Zero(t, fsys.opened["main.tf"])
+
+	encoded, err := json.Marshal(flat[0])
+	require.NoError(t, err)
+	assert.NotContains(t, string(encoded), "fingerprint")
+}
@@ -6,22 +6,28 @@ import (
 )
 
 type FlatResult struct {
-	RuleID          string             `json:"rule_id"`
-	LongID          string             `json:"long_id"`
-	RuleSummary     string             `json:"rule_description"`
-	RuleProvider    providers.Provider `json:"rule_provider"`
-	RuleService     string             `json:"rule_service"`
-	Impact          string             `json:"impact"`
-	Resolution      string             `json:"resolution"`
-	Links           []string           `json:"links"`
-	Description     string             `json:"description"`
-	RangeAnnotation string             `json:"-"`
-	Severity        severity.Severity  `json:"severity"`
-	Warning         bool               `json:"warning"`
-	Status          Status             `json:"status"`
-	Resource        string             `json:"resource"`
-	Occurrences     []Occurrence       `json:"occurrences,omitempty"`
-	Location        FlatRange          `json:"location"`
+	RuleID          string                 `json:"rule_id"`
+	LongID          string                 `json:"long_id"`
+	RuleSummary     string                 `json:"rule_description"`
+	RuleProvider    providers.Provider     `json:"rule_provider"`
+	RuleService     string                 `json:"rule_service"`
+	Impact          string                 `json:"impact"`
+	Resolution      string                 `json:"resolution"`
+	Links           []string               `json:"links"`
+	Description     string                 `json:"description"`
+	RangeAnnotation string                 `json:"-"`
+	Severity        severity.Severity      `json:"severity"`
+	Warning         bool                   `json:"warning"`
+	Status          Status                 `json:"status"`
+	Resource        string                 `json:"resource"`
+	Occurrences     []Occurrence           `json:"occurrences,omitempty"`
+	Location        FlatRange              `json:"location"`
+	Suppression     *Suppression           `json:"suppression,omitempty"`
+	Fields          map[string]interface{} `json:"fields,omitempty"`
+	Fix             *Fix                   `json:"fix,omitempty"`
+	Error           string                 `json:"error,omitempty"`
+	Explanation     *Explanation           `json:"explanation,omitempty"`
+	Fingerprint     string                 `json:"fingerprint,omitempty"`
 }
 
 type FlatRange struct {
@@ -32,13 +38,18 @@ type FlatRange struct {
 
 func (r Results) Flatten() []FlatResult {
 	var results []FlatResult
+	fingerprints := newFingerprinter()
 	for _, original := range r {
-		results = append(results, original.Flatten())
+		results = append(results, original.flatten(fingerprints))
 	}
 	return results
 }
 
 func (r *Result) Flatten() FlatResult {
+	return r.flatten(newFingerprinter())
+}
+
+func (r *Result) flatten(fingerprints *fingerprinter) FlatResult {
 	rng := r.metadata.Range()
 
 	resMetadata := r.metadata
@@ -47,26 +58,36 @@ func (r *Result) Flatten() FlatResult {
 		resMetadata = *resMetadata.Parent()
 	}
 
-	return FlatResult{
+	flat := FlatResult{
 		RuleID:          r.rule.AVDID,
 		LongID:          r.Rule().LongID(),
 		RuleSummary:     r.rule.Summary,
 		RuleProvider:    r.rule.Provider,
 		RuleService:     r.rule.Service,
 		Impact:          r.rule.Impact,
-		Resolution:      r.rule.Resolution,
-		Links:           r.rule.Links,
+		Resolution:      r.Remediation(),
+		Links:           r.Links(),
 		Description:     r.Description(),
 		RangeAnnotation: r.Annotation(),
-		Severity:        r.rule.Severity,
+		Severity:        r.Severity(),
 		Status:          r.status,
 		Resource:        resMetadata.Reference(),
 		Occurrences:     r.Occurrences(),
 		Warning:         r.IsWarning(),
+		Suppression:     r.suppression,
+		Fields:          r.fields,
+		Fix:             r.fix,
+		Error:           r.errorReason,
+		Explanation:     r.explanation,
 		Location: FlatRange{
 			Filename:  rng.GetFilename(),
 			StartLine: rng.GetStartLine(),
 			EndLine:   rng.GetEndLine(),
 		},
 	}
+	// only failed results are tracked across scans, so the files of other results are not read to fingerprint them
+	if r.status == StatusFailed {
+		flat.Fingerprint = fingerprints.fingerprint(r)
+	}
+	return flat
 }
@@ -0,0 +1,238 @@
+package scan
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// JUnitWriter writes results as JUnit XML for CI systems which display test reports. Each provider and service is a
+// test suite, and each rule and resource within it a test case, which fails if any of its results failed, errors if any
+// could not be evaluated, and is skipped if all of its results were ignored.
+type JUnitWriter struct {
+	name string
+}
+
+type JUnitOption func(*JUnitWriter)
+
+// OptionJUnitWithName sets the name of the root testsuites element
+func OptionJUnitWithName(name string) JUnitOption {
+	return func(w *JUnitWriter) {
+		w.name = name
+	}
+}
+
+func NewJUnitWriter(opts ...JUnitOption) *JUnitWriter {
+	w := &JUnitWriter{name: "misscan"}
+	for _, opt := range opts {
+		opt(w)
+	}
+	return w
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Errors   int             `xml:"errors,attr"`
+	Skipped  int             `xml:"skipped,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	File      string        `xml:"file,attr,omitempty"`
+	Line      int           `xml:"line,attr,omitempty"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr,omitempty"`
+	Type    string `xml:"type,attr,omitempty"`
+	Text    string `xml:",chardata"`
+}
+
+type junitCase struct {
+	testCase junitTestCase
+	failed   []*Result
+	errored  []*Result
+	ignored  []*Result
+	passed   int
+}
+
+// Write encodes the results as indented JUnit XML
+func (w *JUnitWriter) Write(out io.Writer, results Results) error {
+	suites := make(map[string][]*junitCase)
+	cases := make(map[string]*junitCase)
+
+	for i := range results {
+		result := &results[i]
+		suite := junitSuiteName(result)
+		id := reportRuleID(result)
+		resource := junitResource(result)
+		key := suite + "\x00" + id + "\x00" + resource
+
+		c, ok := cases[key]
+		if !ok {
+			c = &junitCase{
+				testCase: junitTestCase{
+					Name:      fmt.Sprintf("[%s] %s", id, resource),
+					Classname: junitClassname(result, suite),
+				},
+			}
+			if rng := result.Range(); rng.GetFilename() != "" {
+				c.testCase.File = reportPath(rng.GetFilename())
+				c.testCase.Line = rng.GetStartLine()
+			}
+			cases[key] = c
+			suites[suite] = append(suites[suite], c)
+		}
+
+		switch result.Status() {
+		case StatusFailed:
+			c.failed = append(c.failed, result)
+		case StatusError:
+			c.errored = append(c.errored, result)
+		case StatusIgnored:
+			c.ignored = append(c.ignored, result)
+		default:
+			c.passed++
+		}
+	}
+
+	names := make([]string, 0, len(suites))
+	for name := range suites {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	report := junitTestSuites{Name: w.name}
+	for _, name := range names {
+		suite := junitTestSuite{Name: name}
+		for _, c := range suites[name] {
+			testCase := c.testCase
+			switch {
+			case len(c.failed) > 0:
+				testCase.Failure = junitFailure(c.failed)
+				suite.Failures++
+			case len(c.errored) > 0:
+				testCase.Error = &junitMessage{
+					Message: c.errored[0].ErrorReason(),
+					Text:    junitDetails(c.errored),
+				}
+				suite.Errors++
+			case len(c.ignored) > 0 && c.passed == 0:
+				testCase.Skipped = &junitMessage{Message: junitSkipReason(c.ignored[0])}
+				suite.Skipped++
+			}
+			suite.Cases = append(suite.Cases, testCase)
+			suite.Tests++
+		}
+		report.Suites = append(report.Suites, suite)
+		report.Tests += suite.Tests
+		report.Failures += suite.Failures
+		report.Errors += suite.Errors
+		report.Skipped += suite.Skipped
+	}
+
+	if _, err := io.WriteString(out, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(out)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		return err
+	}
+	_, err := io.WriteString(out, "\n")
+	return err
+}
+
+// junitSuiteName groups results by the provider and service of their rule, e.g. aws/s3
+func junitSuiteName(result *Result) string {
+	rule := result.Rule()
+	switch {
+	case rule.Provider != "" && rule.Service != "":
+		return fmt.Sprintf("%s/%s", rule.Provider, rule.Service)
+	case rule.Provider != "":
+		return string(rule.Provider)
+	case result.RegoNamespace() != "":
+		return result.RegoNamespace()
+	default:
+		return "general"
+	}
+}
+
+func junitClassname(result *Result, suite string) string {
+	if rule := result.Rule(); rule.ShortCode != "" {
+		return rule.LongID()
+	}
+	return suite
+}
+
+// junitResource names the resource a result belongs to, the closest resource containing it, or otherwise its file
+func junitResource(result *Result) string {
+	for metadata := result.Metadata(); ; metadata = *metadata.Parent() {
+		if reference := metadata.Reference(); reference != "" {
+			return reference
+		}
+		if metadata.Parent() == nil {
+			break
+		}
+	}
+	if filename := result.Range().GetFilename(); filename != "" {
+		return reportPath(filename)
+	}
+	return "unknown"
+}
+
+func junitFailure(failed []*Result) *junitMessage {
+	first := failed[0]
+	text := junitDetails(failed)
+	if resolution := first.Remediation(); resolution != "" {
+		text += "\n\nResolution: " + resolution
+	}
+	if links := first.Links(); len(links) > 0 {
+		text += "\n\n" + strings.Join(links, "\n")
+	}
+	return &junitMessage{
+		Message: reportMessage(first),
+		Type:    string(first.Severity()),
+		Text:    text,
+	}
+}
+
+// junitDetails lists each result with its location
+func junitDetails(results []*Result) string {
+	lines := make([]string, 0, len(results))
+	for _, result := range results {
+		rng := result.Range()
+		location := reportPath(rng.GetFilename())
+		if rng.GetStartLine() > 0 {
+			location = fmt.Sprintf("%s:%d-%d", location, rng.GetStartLine(), rng.GetEndLine())
+		}
+		lines = append(lines, fmt.Sprintf("%s: %s", location, reportMessage(result)))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func junitSkipReason(result *Result) string {
+	if suppression := result.Suppression(); suppression != nil && suppression.Justification != "" {
+		return suppression.Justification
+	}
+	return "ignored"
+}
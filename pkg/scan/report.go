@@ -0,0 +1,96 @@
+package scan
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ReportWriter writes results in a report format consumed by another tool
+type ReportWriter interface {
+	Write(w io.Writer, results Results) error
+}
+
+var (
+	_ ReportWriter = (*SARIFWriter)(nil)
+	_ ReportWriter = (*JUnitWriter)(nil)
+	_ ReportWriter = (*CheckstyleWriter)(nil)
+	_ ReportWriter = (*CodeQualityWriter)(nil)
+)
+
+var reportFormats = struct {
+	sync.RWMutex
+	factories map[string]func() ReportWriter
+}{
+	factories: map[string]func() ReportWriter{
+		"sarif":      func() ReportWriter { return NewSARIFWriter() },
+		"junit":      func() ReportWriter { return NewJUnitWriter() },
+		"checkstyle": func() ReportWriter { return NewCheckstyleWriter() },
+		"gitlab":     func() ReportWriter { return NewCodeQualityWriter() },
+	},
+}
+
+// RegisterReportFormat makes a report format available by name through NewReportWriter, replacing any format already
+// registered with the name
+func RegisterReportFormat(name string, factory func() ReportWriter) {
+	reportFormats.Lock()
+	defer reportFormats.Unlock()
+	reportFormats.factories[strings.ToLower(name)] = factory
+}
+
+// ReportFormats lists the names of the registered report formats
+func ReportFormats() []string {
+	reportFormats.RLock()
+	defer reportFormats.RUnlock()
+	names := make([]string, 0, len(reportFormats.factories))
+	for name := range reportFormats.factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewReportWriter returns a writer with default settings for a registered report format
+func NewReportWriter(format string) (ReportWriter, error) {
+	reportFormats.RLock()
+	factory, ok := reportFormats.factories[strings.ToLower(format)]
+	reportFormats.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unsupported report format %q, expected one of %s", format, strings.Join(ReportFormats(), ", "))
+	}
+	return factory(), nil
+}
+
+// reportRuleID identifies the rule of a result by its AVD ID, falling back to its long ID or rego rule
+func reportRuleID(result *Result) string {
+	rule := result.Rule()
+	switch {
+	case rule.AVDID != "":
+		return rule.AVDID
+	case rule.ShortCode != "":
+		return rule.LongID()
+	case result.RegoNamespace() != "":
+		return result.RegoNamespace() + "." + result.RegoRule()
+	default:
+		return "unknown"
+	}
+}
+
+// reportMessage describes a result by its description, or otherwise the summary of its rule
+func reportMessage(result *Result) string {
+	if description := result.Description(); description != "" {
+		return description
+	}
+	if summary := result.Rule().Summary; summary != "" {
+		return summary
+	}
+	return reportRuleID(result)
+}
+
+// reportPath returns the slash separated path of a file relative to the root of the scan
+func reportPath(filename string) string {
+	return strings.TrimPrefix(filepath.ToSlash(filename), "/")
+}
@@ -0,0 +1,243 @@
+package scan_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"testing"
+
+	"github.com/khulnasoft-lab/misscan/pkg/providers"
+	"github.com/khulnasoft-lab/misscan/pkg/scan"
+	"github.com/khulnasoft-lab/misscan/pkg/severity"
+	"github.com/khulnasoft-lab/misscan/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// reportTestResults adds an ignored result of a second rule in another service to the SARIF test results
+func reportTestResults(t *testing.T) scan.Results {
+	results := sarifTestResults(t)
+
+	var ignored scan.Results
+	ignored.AddIgnored(types.NewMetadata(types.NewRange("iam.tf", 5, 9, "", nil), "aws_iam_policy.admin"), "Policy allows all actions.")
+	ignored.SetRule(scan.Rule{
+		AVDID:     "AVD-AWS-0057",
+		ShortCode: "no-policy-wildcards",
+		Provider:  providers.AWSProvider,
+		Service:   "iam",
+		Severity:  severity.Medium,
+	})
+	return append(results, ignored...)
+}
+
+func Test_JUnitWriter(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, scan.NewJUnitWriter().Write(&buf, reportTestResults(t)))
+
+	var report struct {
+		Name     string `xml:"name,attr"`
+		Tests    int    `xml:"tests,attr"`
+		Failures int    `xml:"failures,attr"`
+		Errors   int    `xml:"errors,attr"`
+		Skipped  int    `xml:"skipped,attr"`
+		Suites   []struct {
+			Name  string `xml:"name,attr"`
+			Tests int    `xml:"tests,attr"`
+			Cases []struct {
+				Name      string `xml:"name,attr"`
+				Classname string `xml:"classname,attr"`
+				File      string `xml:"file,attr"`
+				Line      int    `xml:"line,attr"`
+				Failure   *struct {
+					Message string `xml:"message,attr"`
+					Type    string `xml:"type,attr"`
+					Text    string `xml:",chardata"`
+				} `xml:"failure"`
+				Error *struct {
+					Message string `xml:"message,attr"`
+				} `xml:"error"`
+				Skipped *struct {
+					Message string `xml:"message,attr"`
+				} `xml:"skipped"`
+			} `xml:"testcase"`
+		} `xml:"testsuite"`
+	}
+	require.NoError(t, xml.Unmarshal(buf.Bytes(), &report))
+
+	assert.Equal(t, "misscan", report.Name)
+	assert.Equal(t, 3, report.Tests)
+	assert.Equal(t, 1, report.Failures)
+	assert.Equal(t, 1, report.Errors)
+	assert.Equal(t, 1, report.Skipped)
+
+	require.Len(t, report.Suites, 3)
+	assert.Equal(t, "aws/iam", report.Suites[0].Name)
+	assert.Equal(t, "aws/s3", report.Suites[1].Name)
+	assert.Equal(t, "builtin.aws.s3.test", report.Suites[2].Name)
+
+	require.Len(t, report.Suites[0].Cases, 1)
+	skipped := report.Suites[0].Cases[0]
+	assert.Equal(t, "[AVD-AWS-0057] aws_iam_policy.admin", skipped.Name)
+	require.NotNil(t, skipped.Skipped)
+	assert.Equal(t, "ignored", skipped.Skipped.Message)
+
+	require.Len(t, report.Suites[1].Cases, 1, "results of the same rule and resource share a test case")
+	failed := report.Suites[1].Cases[0]
+	assert.Equal(t, "[AVD-AWS-0092] aws_s3_bucket.example", failed.Name)
+	assert.Equal(t, "aws-s3-no-public-access-with-acl", failed.Classname)
+	assert.Equal(t, "modules/bucket/main.tf", failed.File)
+	assert.Equal(t, 3, failed.Line)
+	require.NotNil(t, failed.Failure)
+	assert.Equal(t, "Bucket has a public ACL: 'public-read'.", failed.Failure.Message)
+	assert.Equal(t, "HIGH", failed.Failure.Type)
+	assert.Contains(t, failed.Failure.Text, "modules/bucket/main.tf:3-3")
+	assert.Contains(t, failed.Failure.Text, "Resolution: Don't use canned ACLs or switch to private acl")
+	assert.Nil(t, failed.Skipped)
+
+	require.Len(t, report.Suites[2].Cases, 1)
+	require.NotNil(t, report.Suites[2].Cases[0].Error)
+	assert.Equal(t, "evaluation timed out", report.Suites[2].Cases[0].Error.Message)
+}
+
+func Test_CheckstyleWriter(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, scan.NewCheckstyleWriter().Write(&buf, reportTestResults(t)))
+
+	var report struct {
+		Version string `xml:"version,attr"`
+		Files   []struct {
+			Name   string `xml:"name,attr"`
+			Errors []struct {
+				Line     int    `xml:"line,attr"`
+				Severity string `xml:"severity,attr"`
+				Message  string `xml:"message,attr"`
+				Source   string `xml:"source,attr"`
+			} `xml:"error"`
+		} `xml:"file"`
+	}
+	require.NoError(t, xml.Unmarshal(buf.Bytes(), &report))
+
+	assert.Equal(t, "4.3", report.Version)
+	require.Len(t, report.Files, 1, "only failed results are reported")
+	assert.Equal(t, "modules/bucket/main.tf", report.Files[0].Name)
+	require.Len(t, report.Files[0].Errors, 1)
+	assert.Equal(t, 3, report.Files[0].Errors[0].Line)
+	assert.Equal(t, "error", report.Files[0].Errors[0].Severity)
+	assert.Equal(t, "Bucket has a public ACL: 'public-read'. (aws_s3_bucket.example)", report.Files[0].Errors[0].Message)
+	assert.Equal(t, "AVD-AWS-0092", report.Files[0].Errors[0].Source)
+}
+
+type codeQualityIssue struct {
+	Description string `json:"description"`
+	CheckName   string `json:"check_name"`
+	Fingerprint string `json:"fingerprint"`
+	Severity    string `json:"severity"`
+	Location    struct {
+		Path  string `json:"path"`
+		Lines struct {
+			Begin int `json:"begin"`
+			End   int `json:"end"`
+		} `json:"lines"`
+	} `json:"location"`
+}
+
+func writeCodeQuality(t *testing.T, results scan.Results) []codeQualityIssue {
+	var buf bytes.Buffer
+	require.NoError(t, scan.NewCodeQualityWriter().Write(&buf, results))
+	var issues []codeQualityIssue
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &issues))
+	return issues
+}
+
+func Test_CodeQualityWriter(t *testing.T) {
+	issues := writeCodeQuality(t, reportTestResults(t))
+	require.Len(t, issues, 1, "only failed results are reported")
+	issue := issues[0]
+	assert.Equal(t, "Bucket has a public ACL: 'public-read'.", issue.Description)
+	assert.Equal(t, "AVD-AWS-0092", issue.CheckName)
+	assert.Equal(t, "critical", issue.Severity)
+	assert.Equal(t, "modules/bucket/main.tf", issue.Location.Path)
+	assert.Equal(t, 3, issue.Location.Lines.Begin)
+	assert.Equal(t, 3, issue.Location.Lines.End)
+	assert.Len(t, issue.Fingerprint, 64)
+
+	t.Run("fingerprints do not depend on lines", func(t *testing.T) {
+		moved := func(start, end int) scan.Results {
+			var results scan.Results
+			results.Add("Bucket has a public ACL: 'public-read'.",
+				types.NewMetadata(types.NewRange("modules/bucket/main.tf", start, end, "", nil), "aws_s3_bucket.example"))
+			results.SetRule(scan.Rule{AVDID: "AVD-AWS-0092"})
+			return results
+		}
+		assert.Equal(t, writeCodeQuality(t, moved(3, 3))[0].Fingerprint, writeCodeQuality(t, moved(10, 12))[0].Fingerprint)
+	})
+
+	t.Run("fingerprints do not depend on other results", func(t *testing.T) {
+		module := types.NewMetadata(types.NewRange("main.tf", 1, 3, "", nil), "module.buckets")
+		bucket := func(name string) scan.Results {
+			var results scan.Results
+			results.Add("Bucket has a public ACL: 'public-read'.",
+				types.NewMetadata(types.NewRange("modules/bucket/main.tf", 3, 3, "", nil), "aws_s3_bucket."+name).WithParent(module))
+			results.SetRule(scan.Rule{AVDID: "AVD-AWS-0092"})
+			return results
+		}
+		both := writeCodeQuality(t, append(bucket("a"), bucket("b")...))
+		require.Len(t, both, 2)
+		assert.NotEqual(t, both[0].Fingerprint, both[1].Fingerprint)
+		assert.Equal(t, both[1].Fingerprint, writeCodeQuality(t, bucket("b"))[0].Fingerprint)
+	})
+
+	t.Run("duplicate results are reported once whatever their order", func(t *testing.T) {
+		duplicates := func(lines ...int) scan.Results {
+			var results scan.Results
+			for _, line := range lines {
+				results.Add("Bucket has a public ACL: 'public-read'.",
+					types.NewMetadata(types.NewRange("modules/bucket/main.tf", line, line, "", nil), "aws_s3_bucket.example"))
+			}
+			results.SetRule(scan.Rule{AVDID: "AVD-AWS-0092"})
+			return results
+		}
+		issues := writeCodeQuality(t, duplicates(3, 7, 5))
+		require.Len(t, issues, 1)
+		assert.Equal(t, 3, issues[0].Location.Lines.Begin)
+		for _, lines := range [][]int{{5, 3, 7}, {7, 5, 3}, {3}} {
+			assert.Equal(t, issues, writeCodeQuality(t, duplicates(lines...)), lines)
+		}
+	})
+
+	t.Run("no results", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, scan.NewCodeQualityWriter().Write(&buf, nil))
+		assert.JSONEq(t, "[]", buf.String())
+	})
+}
+
+type countingWriter struct{}
+
+func (countingWriter) Write(w io.Writer, results scan.Results) error {
+	_, err := w.Write([]byte{byte('0' + len(results))})
+	return err
+}
+
+func Test_ReportFormats(t *testing.T) {
+	for _, format := range []string{"sarif", "junit", "checkstyle", "gitlab"} {
+		writer, err := scan.NewReportWriter(format)
+		require.NoError(t, err, format)
+		var buf bytes.Buffer
+		require.NoError(t, writer.Write(&buf, reportTestResults(t)), format)
+		assert.NotEmpty(t, buf.String(), format)
+	}
+
+	_, err := scan.NewReportWriter("html")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "checkstyle, gitlab, junit, sarif")
+
+	scan.RegisterReportFormat("Count", func() scan.ReportWriter { return countingWriter{} })
+	assert.Contains(t, scan.ReportFormats(), "count")
+	writer, err := scan.NewReportWriter("count")
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	require.NoError(t, writer.Write(&buf, reportTestResults(t)))
+	assert.Equal(t, "5", buf.String())
+}
@@ -18,8 +18,24 @@ const (
 	StatusFailed Status = iota
 	StatusPassed
 	StatusIgnored
+	StatusError // the rule could not be evaluated, e.g. because it timed out
 )
 
+func (s Status) String() string {
+	switch s {
+	case StatusFailed:
+		return "failed"
+	case StatusPassed:
+		return "passed"
+	case StatusIgnored:
+		return "ignored"
+	case StatusError:
+		return "error"
+	default:
+		return "unknown"
+	}
+}
+
 type Result struct {
 	rule             Rule
 	description      string
@@ -32,6 +48,35 @@ type Result struct {
 	warning          bool
 	traces           []string
 	fsPath           string
+	suppression      *Suppression
+	remediation      string
+	links            []string
+	fields           map[string]interface{}
+	fix              *Fix
+	errorReason      string
+	explanation      *Explanation
+}
+
+// Fix is a structured suggestion for resolving a single finding
+type Fix struct {
+	Description string    `json:"description,omitempty"`
+	Edits       []FixEdit `json:"edits,omitempty"`
+}
+
+// FixEdit replaces the given (1-based, inclusive) lines of a file with new content
+type FixEdit struct {
+	Filename  string `json:"filename,omitempty"`
+	StartLine int    `json:"start_line"`
+	EndLine   int    `json:"end_line"`
+	Content   string `json:"content"`
+}
+
+// Suppression records why a result was ignored by a declarative exception
+type Suppression struct {
+	Justification string `json:"justification"`
+	Owner         string `json:"owner,omitempty"`
+	Expires       string `json:"expires,omitempty"`
+	Source        string `json:"source,omitempty"`
 }
 
 func (r Result) RegoNamespace() string {
@@ -73,6 +118,85 @@ func (r *Result) OverrideAnnotation(annotation string) {
 	r.annotation = annotation
 }
 
+// OverrideRemediation replaces the rule's resolution for this result only
+func (r *Result) OverrideRemediation(remediation string) {
+	r.remediation = remediation
+}
+
+// Remediation returns the result specific remediation if there is one, or the rule's resolution otherwise
+func (r Result) Remediation() string {
+	if r.remediation != "" {
+		return r.remediation
+	}
+	return r.rule.Resolution
+}
+
+// AddLinks adds links specific to this result, which are listed before the rule's links
+func (r *Result) AddLinks(links ...string) {
+	r.links = append(r.links, links...)
+}
+
+func (r Result) Links() []string {
+	if len(r.links) == 0 {
+		return r.rule.Links
+	}
+	seen := make(map[string]struct{})
+	var links []string
+	for _, link := range append(append([]string{}, r.links...), r.rule.Links...) {
+		if _, ok := seen[link]; ok {
+			continue
+		}
+		seen[link] = struct{}{}
+		links = append(links, link)
+	}
+	return links
+}
+
+// SetField attaches an extra key/value pair to the result
+func (r *Result) SetField(key string, value interface{}) {
+	if r.fields == nil {
+		r.fields = make(map[string]interface{})
+	}
+	r.fields[key] = value
+}
+
+func (r Result) Fields() map[string]interface{} {
+	return r.fields
+}
+
+func (r *Result) SetFix(fix Fix) {
+	r.fix = &fix
+}
+
+func (r Result) Fix() *Fix {
+	return r.fix
+}
+
+// SetExplanation records why the rule produced this result
+func (r *Result) SetExplanation(explanation Explanation) {
+	r.explanation = &explanation
+}
+
+// Explanation returns the expressions which produced this result, if explanations were enabled on the scanner
+func (r Result) Explanation() *Explanation {
+	return r.explanation
+}
+
+// Suppress marks the result as ignored, recording the reason
+func (r *Result) Suppress(suppression Suppression) {
+	r.status = StatusIgnored
+	r.suppression = &suppression
+}
+
+// ErrorReason returns why the rule could not be evaluated, for results with StatusError
+func (r Result) ErrorReason() string {
+	return r.errorReason
+}
+
+func (r Result) Suppression() *Suppression {
+	return r.suppression
+}
+
 func (r *Result) SetRule(ru Rule) {
 	r.rule = ru
 }
@@ -164,6 +288,10 @@ func (r *Results) GetFailed() Results {
 	return r.filterStatus(StatusFailed)
 }
 
+func (r *Results) GetErrored() Results {
+	return r.filterStatus(StatusError)
+}
+
 func (r *Results) filterStatus(status Status) Results {
 	var filtered Results
 	if r == nil {
@@ -251,6 +379,21 @@ func (r *Results) AddPassedRego(namespace string, rule string, traces []string,
 	*r = append(*r, res)
 }
 
+// AddErrorRego records that a rego rule could not be evaluated against the source, and why
+func (r *Results) AddErrorRego(reason string, namespace string, rule string, source interface{}) {
+	res := Result{
+		description:   reason,
+		status:        StatusError,
+		regoNamespace: namespace,
+		regoRule:      rule,
+		errorReason:   reason,
+	}
+	res.metadata = getMetadataFromSource(source)
+	rnge := res.metadata.Range()
+	res.fsPath = rnge.GetLocalFilename()
+	*r = append(*r, res)
+}
+
 func (r *Results) AddIgnored(source interface{}, descriptions ...string) {
 	res := Result{
 		description: strings.Join(descriptions, " "),
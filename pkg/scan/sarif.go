@@ -0,0 +1,490 @@
+package scan
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/khulnasoft-lab/misscan/pkg/severity"
+)
+
+const (
+	sarifVersion = "2.1.0"
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+)
+
+type sarifSettings struct {
+	toolName       string
+	toolVersion    string
+	informationURI string
+	includePassed  bool
+	codeOptions    []CodeOption
+}
+
+var defaultSARIFSettings = sarifSettings{
+	toolName:       "misscan",
+	informationURI: "https://github.com/khulnasoft-lab/misscan",
+	codeOptions: []CodeOption{
+		OptionCodeWithHighlighted(false),
+		OptionCodeWithTruncation(false),
+	},
+}
+
+type SARIFOption func(*sarifSettings)
+
+func OptionSARIFWithToolName(name string) SARIFOption {
+	return func(s *sarifSettings) {
+		s.toolName = name
+	}
+}
+
+func OptionSARIFWithToolVersion(version string) SARIFOption {
+	return func(s *sarifSettings) {
+		s.toolVersion = version
+	}
+}
+
+func OptionSARIFWithInformationURI(uri string) SARIFOption {
+	return func(s *sarifSettings) {
+		s.informationURI = uri
+	}
+}
+
+// OptionSARIFWithPassed includes passed results, with a kind of "pass"
+func OptionSARIFWithPassed(include bool) SARIFOption {
+	return func(s *sarifSettings) {
+		s.includePassed = include
+	}
+}
+
+// OptionSARIFWithCodeOptions configures how code snippets are read for each result
+func OptionSARIFWithCodeOptions(opts ...CodeOption) SARIFOption {
+	return func(s *sarifSettings) {
+		s.codeOptions = opts
+	}
+}
+
+// SARIFWriter writes results as a SARIF 2.1.0 log with a single run. Failed results are reported with a level derived
+// from their severity, ignored results carry a suppression, and errored results are reported as tool execution
+// notifications rather than results.
+type SARIFWriter struct {
+	settings sarifSettings
+}
+
+func NewSARIFWriter(opts ...SARIFOption) *SARIFWriter {
+	settings := defaultSARIFSettings
+	for _, opt := range opts {
+		opt(&settings)
+	}
+	return &SARIFWriter{settings: settings}
+}
+
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool        sarifTool         `json:"tool"`
+	Results     []sarifResult     `json:"results"`
+	Invocations []sarifInvocation `json:"invocations"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	Version        string      `json:"version,omitempty"`
+	InformationURI string      `json:"informationUri,omitempty"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID                   string                 `json:"id"`
+	Name                 string                 `json:"name,omitempty"`
+	ShortDescription     *sarifMessage          `json:"shortDescription,omitempty"`
+	FullDescription      *sarifMessage          `json:"fullDescription,omitempty"`
+	Help                 *sarifMultiformat      `json:"help,omitempty"`
+	HelpURI              string                 `json:"helpUri,omitempty"`
+	DefaultConfiguration sarifConfiguration     `json:"defaultConfiguration"`
+	Properties           map[string]interface{} `json:"properties,omitempty"`
+}
+
+type sarifConfiguration struct {
+	Level string `json:"level"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifMultiformat struct {
+	Text     string `json:"text"`
+	Markdown string `json:"markdown,omitempty"`
+}
+
+type sarifResult struct {
+	RuleID           string                 `json:"ruleId"`
+	RuleIndex        int                    `json:"ruleIndex"`
+	Kind             string                 `json:"kind,omitempty"`
+	Level            string                 `json:"level"`
+	Message          sarifMessage           `json:"message"`
+	Locations        []sarifLocation        `json:"locations,omitempty"`
+	RelatedLocations []sarifLocation        `json:"relatedLocations,omitempty"`
+	Suppressions     []sarifSuppression     `json:"suppressions,omitempty"`
+	Fingerprints     map[string]string      `json:"fingerprints,omitempty"`
+	Properties       map[string]interface{} `json:"properties,omitempty"`
+}
+
+type sarifLocation struct {
+	ID               *int                   `json:"id,omitempty"`
+	PhysicalLocation *sarifPhysicalLocation `json:"physicalLocation,omitempty"`
+	LogicalLocations []sarifLogicalLocation `json:"logicalLocations,omitempty"`
+	Message          *sarifMessage          `json:"message,omitempty"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine int           `json:"startLine"`
+	EndLine   int           `json:"endLine,omitempty"`
+	Snippet   *sarifMessage `json:"snippet,omitempty"`
+}
+
+type sarifLogicalLocation struct {
+	FullyQualifiedName string `json:"fullyQualifiedName"`
+	Kind               string `json:"kind,omitempty"`
+}
+
+type sarifSuppression struct {
+	Kind          string                 `json:"kind"`
+	Status        string                 `json:"status,omitempty"`
+	Justification string                 `json:"justification,omitempty"`
+	Properties    map[string]interface{} `json:"properties,omitempty"`
+}
+
+type sarifInvocation struct {
+	ExecutionSuccessful        bool                `json:"executionSuccessful"`
+	ToolExecutionNotifications []sarifNotification `json:"toolExecutionNotifications,omitempty"`
+}
+
+type sarifNotification struct {
+	Level      string          `json:"level"`
+	Message    sarifMessage    `json:"message"`
+	Descriptor *sarifReference `json:"descriptor,omitempty"`
+	Locations  []sarifLocation `json:"locations,omitempty"`
+}
+
+type sarifReference struct {
+	ID string `json:"id"`
+}
+
+// Write encodes the results as an indented SARIF log
+func (s *SARIFWriter) Write(w io.Writer, results Results) error {
+	run := sarifRun{
+		Tool: sarifTool{
+			Driver: sarifDriver{
+				Name:           s.settings.toolName,
+				Version:        s.settings.toolVersion,
+				InformationURI: s.settings.informationURI,
+				Rules:          []sarifRule{},
+			},
+		},
+		Results: []sarifResult{},
+	}
+	invocation := sarifInvocation{ExecutionSuccessful: true}
+
+	ruleIndexes := make(map[string]int)
+	fingerprints := newFingerprinter()
+	for i := range results {
+		result := &results[i]
+		id := reportRuleID(result)
+
+		if result.Status() == StatusError {
+			invocation.ToolExecutionNotifications = append(invocation.ToolExecutionNotifications, sarifNotification{
+				Level:      "error",
+				Message:    sarifMessage{Text: result.ErrorReason()},
+				Descriptor: &sarifReference{ID: id},
+				Locations:  s.locations(result, false),
+			})
+			continue
+		}
+		if result.Status() == StatusPassed && !s.settings.includePassed {
+			continue
+		}
+
+		index, ok := ruleIndexes[id]
+		if !ok {
+			index = len(run.Tool.Driver.Rules)
+			ruleIndexes[id] = index
+			run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRuleFor(id, result.Rule()))
+		}
+
+		converted := sarifResult{
+			RuleID:           id,
+			RuleIndex:        index,
+			Level:            sarifLevel(result.Severity(), result.IsWarning()),
+			Message:          sarifMessage{Text: reportMessage(result)},
+			Locations:        s.locations(result, true),
+			RelatedLocations: sarifRelatedLocations(result),
+			Fingerprints: map[string]string{
+				fmt.Sprintf("misscan/v%d", FingerprintVersion): fingerprints.fingerprint(result),
+			},
+			Properties: sarifResultProperties(result),
+		}
+		switch result.Status() {
+		case StatusPassed:
+			converted.Kind = "pass"
+			converted.Level = "none"
+		case StatusIgnored:
+			converted.Suppressions = []sarifSuppression{sarifSuppressionFor(result.Suppression())}
+		}
+		run.Results = append(run.Results, converted)
+	}
+	run.Invocations = []sarifInvocation{invocation}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(sarifLog{
+		Version: sarifVersion,
+		Schema:  sarifSchema,
+		Runs:    []sarifRun{run},
+	})
+}
+
+// sarifRuleFor describes a rule using only the rule's own metadata, as overrides of individual results are reported
+// on the results themselves
+func sarifRuleFor(id string, rule Rule) sarifRule {
+	converted := sarifRule{
+		ID:   id,
+		Name: rule.LongID(),
+		DefaultConfiguration: sarifConfiguration{
+			Level: sarifLevel(rule.Severity, false),
+		},
+		Properties: map[string]interface{}{
+			"precision": "very-high",
+			"tags":      sarifTags(rule),
+		},
+	}
+	if rule.ShortCode == "" {
+		converted.Name = ""
+	}
+	if rule.Summary != "" {
+		converted.ShortDescription = &sarifMessage{Text: rule.Summary}
+	}
+	if rule.Explanation != "" {
+		converted.FullDescription = &sarifMessage{Text: rule.Explanation}
+	}
+	if score, ok := sarifSecuritySeverity[rule.Severity]; ok {
+		converted.Properties["security-severity"] = score
+	}
+
+	if len(rule.Links) > 0 {
+		converted.HelpURI = rule.Links[0]
+	}
+	converted.Help = sarifHelp(rule.Resolution, rule.Links)
+	return converted
+}
+
+// sarifResultProperties returns the remediation and links which were overridden for this result, if any
+func sarifResultProperties(result *Result) map[string]interface{} {
+	properties := make(map[string]interface{})
+	if result.remediation != "" {
+		properties["remediation"] = result.remediation
+	}
+	if len(result.links) > 0 {
+		properties["links"] = result.Links()
+	}
+	if len(properties) == 0 {
+		return nil
+	}
+	return properties
+}
+
+// sarifHelp describes how to resolve a rule's findings, or returns nil if there is no resolution or links
+func sarifHelp(resolution string, links []string) *sarifMultiformat {
+	if resolution == "" && len(links) == 0 {
+		return nil
+	}
+	var text, markdown strings.Builder
+	if resolution != "" {
+		text.WriteString(resolution)
+		markdown.WriteString(resolution)
+	}
+	if len(links) > 0 {
+		if resolution != "" {
+			text.WriteString("\n\n")
+			markdown.WriteString("\n\n")
+		}
+		text.WriteString("Links:\n")
+		markdown.WriteString("#### Links\n")
+		for _, link := range links {
+			_, _ = fmt.Fprintf(&text, "%s\n", link)
+			_, _ = fmt.Fprintf(&markdown, "- [%s](%s)\n", link, link)
+		}
+	}
+	return &sarifMultiformat{
+		Text:     strings.TrimSuffix(text.String(), "\n"),
+		Markdown: strings.TrimSuffix(markdown.String(), "\n"),
+	}
+}
+
+// sarifTags tags a rule as a security rule with its provider and service, and each control of each framework it belongs
+// to as framework:control
+func sarifTags(rule Rule) []string {
+	tags := []string{"security"}
+	if rule.Provider != "" {
+		tags = append(tags, string(rule.Provider))
+	}
+	if rule.Service != "" {
+		tags = append(tags, rule.Service)
+	}
+	var frameworkTags []string
+	for fw, controls := range rule.Frameworks {
+		if len(controls) == 0 {
+			frameworkTags = append(frameworkTags, string(fw))
+			continue
+		}
+		for _, control := range controls {
+			frameworkTags = append(frameworkTags, fmt.Sprintf("%s:%s", fw, control))
+		}
+	}
+	sort.Strings(frameworkTags)
+	return append(tags, frameworkTags...)
+}
+
+// sarifSecuritySeverity scores each severity for dashboards which rank results by CVSS-like scores
+var sarifSecuritySeverity = map[severity.Severity]string{
+	severity.Critical: "9.5",
+	severity.High:     "8.0",
+	severity.Medium:   "5.5",
+	severity.Low:      "2.0",
+}
+
+func sarifLevel(sev severity.Severity, warning bool) string {
+	switch sev {
+	case severity.Critical, severity.High:
+		if warning {
+			return "warning"
+		}
+		return "error"
+	case severity.Medium:
+		return "warning"
+	case severity.Low:
+		return "note"
+	default:
+		if warning {
+			return "warning"
+		}
+		return "error"
+	}
+}
+
+// locations returns the location of the result, with its code snippet if it can be read
+func (s *SARIFWriter) locations(result *Result, withSnippet bool) []sarifLocation {
+	rng := result.Range()
+	if rng.GetFilename() == "" {
+		return nil
+	}
+	physical := &sarifPhysicalLocation{
+		ArtifactLocation: sarifArtifactLocation{URI: reportPath(rng.GetFilename())},
+	}
+	if rng.GetStartLine() > 0 {
+		physical.Region = &sarifRegion{
+			StartLine: rng.GetStartLine(),
+			EndLine:   rng.GetEndLine(),
+		}
+		if withSnippet {
+			s.addSnippet(result, physical)
+		}
+	}
+	location := sarifLocation{PhysicalLocation: physical}
+	if resource := result.Metadata().Reference(); resource != "" {
+		location.LogicalLocations = []sarifLogicalLocation{{FullyQualifiedName: resource, Kind: "resource"}}
+	}
+	return []sarifLocation{location}
+}
+
+// addSnippet sets the snippet of the region to the lines of code causing the result
+func (s *SARIFWriter) addSnippet(result *Result, physical *sarifPhysicalLocation) {
+	code, err := result.GetCode(s.settings.codeOptions...)
+	if err != nil {
+		return
+	}
+	var cause []string
+	for _, line := range code.Lines {
+		if line.IsCause && !line.Truncated {
+			cause = append(cause, line.Content)
+		}
+	}
+	if len(cause) > 0 {
+		physical.Region.Snippet = &sarifMessage{Text: strings.Join(cause, "\n")}
+	}
+}
+
+// sarifRelatedLocations returns the resources which contain the result, such as the module calls it was reached through
+func sarifRelatedLocations(result *Result) []sarifLocation {
+	var related []sarifLocation
+	for i, occurrence := range result.Occurrences() {
+		if occurrence.Filename == "" {
+			continue
+		}
+		id := i + 1
+		location := sarifLocation{
+			ID: &id,
+			PhysicalLocation: &sarifPhysicalLocation{
+				ArtifactLocation: sarifArtifactLocation{URI: reportPath(occurrence.Filename)},
+			},
+		}
+		if occurrence.StartLine > 0 {
+			location.PhysicalLocation.Region = &sarifRegion{
+				StartLine: occurrence.StartLine,
+				EndLine:   occurrence.EndLine,
+			}
+		}
+		if occurrence.Resource != "" {
+			location.Message = &sarifMessage{Text: occurrence.Resource}
+		}
+		related = append(related, location)
+	}
+	return related
+}
+
+// sarifSuppressionFor converts the suppression of an ignored result. Results ignored by an exception in a policy have
+// no suppression recorded, and are reported as suppressed in source.
+func sarifSuppressionFor(suppression *Suppression) sarifSuppression {
+	if suppression == nil {
+		return sarifSuppression{Kind: "inSource", Status: "accepted"}
+	}
+	converted := sarifSuppression{
+		Kind:          "external",
+		Status:        "accepted",
+		Justification: suppression.Justification,
+	}
+	properties := make(map[string]interface{})
+	if suppression.Owner != "" {
+		properties["owner"] = suppression.Owner
+	}
+	if suppression.Expires != "" {
+		properties["expires"] = suppression.Expires
+	}
+	if suppression.Source != "" {
+		properties["source"] = suppression.Source
+	}
+	if len(properties) > 0 {
+		converted.Properties = properties
+	}
+	return converted
+}
@@ -0,0 +1,226 @@
+package scan_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/khulnasoft-lab/misscan/pkg/framework"
+	"github.com/khulnasoft-lab/misscan/pkg/providers"
+	"github.com/khulnasoft-lab/misscan/pkg/scan"
+	"github.com/khulnasoft-lab/misscan/pkg/severity"
+	"github.com/khulnasoft-lab/misscan/pkg/types"
+	"github.com/liamg/memoryfs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func sarifTestResults(t *testing.T) scan.Results {
+	fsys := memoryfs.New()
+	require.NoError(t, fsys.WriteFile("main.tf", []byte(`module "bucket" {
+  source = "./modules/bucket"
+}
+`), 0o600))
+	require.NoError(t, fsys.MkdirAll("modules/bucket", 0o700))
+	require.NoError(t, fsys.WriteFile("modules/bucket/main.tf", []byte(`resource "aws_s3_bucket" "example" {
+  bucket = "example"
+  acl    = "public-read"
+}
+`), 0o600))
+
+	rule := scan.Rule{
+		AVDID:       "AVD-AWS-0092",
+		ShortCode:   "no-public-access-with-acl",
+		Summary:     "S3 Buckets not publicly accessible through ACL.",
+		Explanation: "Buckets should not have ACLs that allow public access",
+		Resolution:  "Don't use canned ACLs or switch to private acl",
+		Provider:    providers.AWSProvider,
+		Service:     "s3",
+		Links:       []string{"https://avd.khulnasoft.com/misconfig/avd-aws-0092"},
+		Severity:    severity.High,
+		Frameworks: map[framework.Framework][]string{
+			framework.Default:     nil,
+			framework.CIS_AWS_1_2: {"2.1"},
+		},
+	}
+
+	call := types.NewMetadata(types.NewRange("main.tf", 1, 3, "", fsys), "module.bucket")
+	resource := types.NewMetadata(types.NewRange("modules/bucket/main.tf", 1, 4, "", fsys), "aws_s3_bucket.example").
+		WithParent(call)
+	acl := types.NewMetadata(types.NewRange("modules/bucket/main.tf", 3, 3, "", fsys), "aws_s3_bucket.example").
+		WithParent(resource)
+
+	var results scan.Results
+	results.Add("Bucket has a public ACL: 'public-read'.", acl)
+	results.AddIgnored(acl, "Bucket has a public ACL: 'public-read'.")
+	results[1].Suppress(scan.Suppression{
+		Justification: "public website",
+		Owner:         "web-team",
+		Source:        ".misscan-exceptions.yaml",
+	})
+	results.AddPassed(resource)
+	results.SetRule(rule)
+	results.AddErrorRego("evaluation timed out", "builtin.aws.s3.test", "deny", acl)
+	return results
+}
+
+func Test_SARIFWriter(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, scan.NewSARIFWriter(scan.OptionSARIFWithToolVersion("1.2.3")).Write(&buf, sarifTestResults(t)))
+
+	var log map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &log))
+	assert.Equal(t, "2.1.0", log["version"])
+
+	runs := log["runs"].([]interface{})
+	require.Len(t, runs, 1)
+	run := runs[0].(map[string]interface{})
+
+	driver := run["tool"].(map[string]interface{})["driver"].(map[string]interface{})
+	assert.Equal(t, "misscan", driver["name"])
+	assert.Equal(t, "1.2.3", driver["version"])
+
+	rules := driver["rules"].([]interface{})
+	require.Len(t, rules, 1, "results of the same rule share a rule descriptor")
+	rule := rules[0].(map[string]interface{})
+	assert.Equal(t, "AVD-AWS-0092", rule["id"])
+	assert.Equal(t, "aws-s3-no-public-access-with-acl", rule["name"])
+	assert.Equal(t, "S3 Buckets not publicly accessible through ACL.", rule["shortDescription"].(map[string]interface{})["text"])
+	assert.Equal(t, "Buckets should not have ACLs that allow public access", rule["fullDescription"].(map[string]interface{})["text"])
+	assert.Equal(t, "https://avd.khulnasoft.com/misconfig/avd-aws-0092", rule["helpUri"])
+	assert.Contains(t, rule["help"].(map[string]interface{})["text"], "Don't use canned ACLs or switch to private acl")
+	assert.Equal(t, "error", rule["defaultConfiguration"].(map[string]interface{})["level"])
+	properties := rule["properties"].(map[string]interface{})
+	assert.Equal(t, "8.0", properties["security-severity"])
+	assert.Equal(t, []interface{}{"security", "aws", "s3", "cis-aws-1.2:2.1", "default"}, properties["tags"])
+
+	results := run["results"].([]interface{})
+	require.Len(t, results, 2, "passed and errored results are not reported as results")
+
+	failed := results[0].(map[string]interface{})
+	assert.Equal(t, "AVD-AWS-0092", failed["ruleId"])
+	assert.Equal(t, float64(0), failed["ruleIndex"])
+	assert.Equal(t, "error", failed["level"])
+	assert.Equal(t, "Bucket has a public ACL: 'public-read'.", failed["message"].(map[string]interface{})["text"])
+	assert.Nil(t, failed["suppressions"])
+
+	location := failed["locations"].([]interface{})[0].(map[string]interface{})
+	physical := location["physicalLocation"].(map[string]interface{})
+	assert.Equal(t, "modules/bucket/main.tf", physical["artifactLocation"].(map[string]interface{})["uri"])
+	region := physical["region"].(map[string]interface{})
+	assert.Equal(t, float64(3), region["startLine"])
+	assert.Equal(t, float64(3), region["endLine"])
+	assert.Equal(t, `  acl    = "public-read"`, region["snippet"].(map[string]interface{})["text"])
+	assert.Equal(t, "aws_s3_bucket.example", location["logicalLocations"].([]interface{})[0].(map[string]interface{})["fullyQualifiedName"])
+
+	related := failed["relatedLocations"].([]interface{})
+	require.Len(t, related, 2)
+	assert.Equal(t, "aws_s3_bucket.example", related[0].(map[string]interface{})["message"].(map[string]interface{})["text"])
+	assert.Equal(t, "module.bucket", related[1].(map[string]interface{})["message"].(map[string]interface{})["text"])
+	assert.Equal(t, "main.tf", related[1].(map[string]interface{})["physicalLocation"].(map[string]interface{})["artifactLocation"].(map[string]interface{})["uri"])
+
+	ignored := results[1].(map[string]interface{})
+	suppressions := ignored["suppressions"].([]interface{})
+	require.Len(t, suppressions, 1)
+	assert.Equal(t, map[string]interface{}{
+		"kind":          "external",
+		"status":        "accepted",
+		"justification": "public website",
+		"properties": map[string]interface{}{
+			"owner":  "web-team",
+			"source": ".misscan-exceptions.yaml",
+		},
+	}, suppressions[0])
+
+	invocation := run["invocations"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, true, invocation["executionSuccessful"])
+	notifications := invocation["toolExecutionNotifications"].([]interface{})
+	require.Len(t, notifications, 1)
+	notification := notifications[0].(map[string]interface{})
+	assert.Equal(t, "evaluation timed out", notification["message"].(map[string]interface{})["text"])
+	assert.Equal(t, "builtin.aws.s3.test.deny", notification["descriptor"].(map[string]interface{})["id"])
+}
+
+func Test_SARIFWriter_Passed(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, scan.NewSARIFWriter(scan.OptionSARIFWithPassed(true)).Write(&buf, sarifTestResults(t)))
+
+	var log struct {
+		Runs []struct {
+			Results []struct {
+				Kind  string `json:"kind"`
+				Level string `json:"level"`
+			} `json:"results"`
+		} `json:"runs"`
+	}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &log))
+	require.Len(t, log.Runs[0].Results, 3)
+	assert.Equal(t, "pass", log.Runs[0].Results[2].Kind)
+	assert.Equal(t, "none", log.Runs[0].Results[2].Level)
+}
+
+func Test_SARIFWriter_Empty(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, scan.NewSARIFWriter().Write(&buf, nil))
+	assert.Contains(t, buf.String(), `"results": []`)
+	assert.Contains(t, buf.String(), `"rules": []`)
+}
+
+func Test_SARIFWriter_ResultOverrides(t *testing.T) {
+	var results scan.Results
+	results.Add("Bucket has a public ACL: 'public-read'.",
+		types.NewMetadata(types.NewRange("main.tf", 3, 3, "", nil), "aws_s3_bucket.example"))
+	results.Add("Bucket has a public ACL: 'public-read-write'.",
+		types.NewMetadata(types.NewRange("main.tf", 8, 8, "", nil), "aws_s3_bucket.other"))
+	results.SetRule(scan.Rule{
+		AVDID:      "AVD-AWS-0092",
+		Resolution: "Don't use canned ACLs or switch to private acl",
+		Links:      []string{"https://avd.khulnasoft.com/misconfig/avd-aws-0092"},
+		Severity:   severity.Medium,
+	})
+	results[0].OverrideSeverity(severity.Critical)
+	results[0].OverrideRemediation("Remove the acl attribute")
+	results[0].AddLinks("https://example.com/acls")
+
+	var buf bytes.Buffer
+	require.NoError(t, scan.NewSARIFWriter().Write(&buf, results))
+
+	var log struct {
+		Runs []struct {
+			Tool struct {
+				Driver struct {
+					Rules []struct {
+						HelpURI string `json:"helpUri"`
+						Help    struct {
+							Text string `json:"text"`
+						} `json:"help"`
+						DefaultConfiguration struct {
+							Level string `json:"level"`
+						} `json:"defaultConfiguration"`
+					} `json:"rules"`
+				} `json:"driver"`
+			} `json:"tool"`
+			Results []struct {
+				Level      string                 `json:"level"`
+				Properties map[string]interface{} `json:"properties"`
+			} `json:"results"`
+		} `json:"runs"`
+	}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &log))
+
+	rules := log.Runs[0].Tool.Driver.Rules
+	require.Len(t, rules, 1)
+	assert.Equal(t, "https://avd.khulnasoft.com/misconfig/avd-aws-0092", rules[0].HelpURI)
+	assert.Equal(t, "Don't use canned ACLs or switch to private acl\n\nLinks:\nhttps://avd.khulnasoft.com/misconfig/avd-aws-0092", rules[0].Help.Text)
+	assert.Equal(t, "warning", rules[0].DefaultConfiguration.Level)
+
+	require.Len(t, log.Runs[0].Results, 2)
+	overridden := log.Runs[0].Results[0]
+	assert.Equal(t, "error", overridden.Level)
+	assert.Equal(t, map[string]interface{}{
+		"remediation": "Remove the acl attribute",
+		"links":       []interface{}{"https://example.com/acls", "https://avd.khulnasoft.com/misconfig/avd-aws-0092"},
+	}, overridden.Properties)
+	assert.Equal(t, "warning", log.Runs[0].Results[1].Level)
+	assert.Nil(t, log.Runs[0].Results[1].Properties)
+}
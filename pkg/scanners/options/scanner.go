@@ -3,8 +3,10 @@ package options
 import (
 	"io"
 	"io/fs"
+	"time"
 
 	"github.com/khulnasoft-lab/misscan/pkg/framework"
+	"github.com/khulnasoft-lab/misscan/pkg/severity"
 )
 
 type ConfigurableScanner interface {
@@ -24,6 +26,25 @@ type ConfigurableScanner interface {
 	SetRegoOnly(regoOnly bool)
 	SetRegoErrorLimit(limit int)
 	SetUseEmbeddedLibraries(bool)
+	SetConcurrency(n int)
+	SetPolicyBundles(paths ...string)
+	SetPolicyBundleVerificationKey(path string)
+	SetCoverageEnabled(bool)
+	SetExceptionsFile(path string)
+	SetStatsWriter(writer io.Writer)
+	SetStrictCompilation(bool)
+	SetAllowedEnv(names ...string)
+	SetBlockedBuiltins(names ...string)
+	SetQueryTimeout(timeout time.Duration)
+	SetIncludedChecks(ids ...string)
+	SetExcludedChecks(ids ...string)
+	SetMinimumSeverity(sev severity.Severity)
+	SetIncludedServices(services ...string)
+	SetExcludedServices(services ...string)
+	SetExplainEnabled(bool)
+	SetPolicyPrecedence(sources ...string)
+	SetLibraryTrimming(bool)
+	SetWasmEnabled(bool)
 }
 
 type ScannerOption func(s ConfigurableScanner)
@@ -126,3 +147,143 @@ func ScannerWithRegoErrorLimits(limit int) ScannerOption {
 		s.SetRegoErrorLimit(limit)
 	}
 }
+
+// ScannerWithConcurrency sets the maximum number of rule evaluations which may run at once - defaults to 1
+func ScannerWithConcurrency(n int) ScannerOption {
+	return func(s ConfigurableScanner) {
+		s.SetConcurrency(n)
+	}
+}
+
+// ScannerWithPolicyBundle loads policies and data from OPA bundles (.tar.gz archives with a .manifest)
+func ScannerWithPolicyBundle(paths ...string) ScannerOption {
+	return func(s ConfigurableScanner) {
+		s.SetPolicyBundles(paths...)
+	}
+}
+
+// ScannerWithPolicyBundleVerificationKey requires bundles to be signed, and verifies their signatures using the PEM
+// encoded RSA or ECDSA public key at the given path
+func ScannerWithPolicyBundleVerificationKey(path string) ScannerOption {
+	return func(s ConfigurableScanner) {
+		s.SetPolicyBundleVerificationKey(path)
+	}
+}
+
+// ScannerWithCoverage records which lines of each policy are executed across all scans
+func ScannerWithCoverage(enabled bool) ScannerOption {
+	return func(s ConfigurableScanner) {
+		s.SetCoverageEnabled(enabled)
+	}
+}
+
+// ScannerWithExceptionsFile loads declarative exceptions (YAML or JSON) which mark matching findings as ignored
+func ScannerWithExceptionsFile(path string) ScannerOption {
+	return func(s ConfigurableScanner) {
+		s.SetExceptionsFile(path)
+	}
+}
+
+// ScannerWithStatsWriter writes compile time and per-rule evaluation statistics as JSON to the writer after each scan
+func ScannerWithStatsWriter(writer io.Writer) ScannerOption {
+	return func(s ConfigurableScanner) {
+		s.SetStatsWriter(writer)
+	}
+}
+
+// ScannerWithStrictCompilation fails policy loading on any compile error, instead of pruning the broken modules
+func ScannerWithStrictCompilation(enabled bool) ScannerOption {
+	return func(s ConfigurableScanner) {
+		s.SetStrictCompilation(enabled)
+	}
+}
+
+// ScannerWithAllowedEnv exposes the named environment variables to policies through opa.runtime().env - by default
+// none are exposed and opa.runtime is blocked. Allowing any variable also unblocks opa.runtime.
+func ScannerWithAllowedEnv(names ...string) ScannerOption {
+	return func(s ConfigurableScanner) {
+		s.SetAllowedEnv(names...)
+	}
+}
+
+// ScannerWithBlockedBuiltins replaces the builtins policies may not call (http.send, net.lookup_ip_addr and
+// opa.runtime by default). Modules calling a blocked builtin are not loaded.
+func ScannerWithBlockedBuiltins(names ...string) ScannerOption {
+	return func(s ConfigurableScanner) {
+		s.SetBlockedBuiltins(names...)
+	}
+}
+
+// ScannerWithQueryTimeout limits how long each policy query may run for. Rules which time out are reported with
+// scan.StatusError rather than aborting the scan.
+func ScannerWithQueryTimeout(timeout time.Duration) ScannerOption {
+	return func(s ConfigurableScanner) {
+		s.SetQueryTimeout(timeout)
+	}
+}
+
+// ScannerWithIncludedChecks limits evaluation to checks matching any of the given AVD IDs, aliases or long IDs
+func ScannerWithIncludedChecks(ids ...string) ScannerOption {
+	return func(s ConfigurableScanner) {
+		s.SetIncludedChecks(ids...)
+	}
+}
+
+// ScannerWithExcludedChecks prevents evaluation of checks matching any of the given AVD IDs, aliases or long IDs
+func ScannerWithExcludedChecks(ids ...string) ScannerOption {
+	return func(s ConfigurableScanner) {
+		s.SetExcludedChecks(ids...)
+	}
+}
+
+// ScannerWithMinimumSeverity prevents evaluation of checks with a lower severity
+func ScannerWithMinimumSeverity(sev severity.Severity) ScannerOption {
+	return func(s ConfigurableScanner) {
+		s.SetMinimumSeverity(sev)
+	}
+}
+
+// ScannerWithIncludedServices limits evaluation to checks for the given providers or services, e.g. "aws" or "aws/s3"
+func ScannerWithIncludedServices(services ...string) ScannerOption {
+	return func(s ConfigurableScanner) {
+		s.SetIncludedServices(services...)
+	}
+}
+
+// ScannerWithExcludedServices prevents evaluation of checks for the given providers or services, e.g. "aws" or "aws/s3"
+func ScannerWithExcludedServices(services ...string) ScannerOption {
+	return func(s ConfigurableScanner) {
+		s.SetExcludedServices(services...)
+	}
+}
+
+// ScannerWithExplain records on each failed result the chain of expressions which produced it, with the input values
+// they referred to
+func ScannerWithExplain(enabled bool) ScannerOption {
+	return func(s ConfigurableScanner) {
+		s.SetExplainEnabled(enabled)
+	}
+}
+
+// ScannerWithPolicyPrecedence sets the order, highest first, in which policies from different sources ("reader",
+// "dir", "bundle" and "embedded") shadow each other when they share an AVD ID or package
+func ScannerWithPolicyPrecedence(sources ...string) ScannerOption {
+	return func(s ConfigurableScanner) {
+		s.SetPolicyPrecedence(sources...)
+	}
+}
+
+// ScannerWithLibraryTrimming removes library packages which none of the loaded checks depend on before compiling
+func ScannerWithLibraryTrimming(enabled bool) ScannerOption {
+	return func(s ConfigurableScanner) {
+		s.SetLibraryTrimming(enabled)
+	}
+}
+
+// ScannerWithWasm compiles policies to Wasm and evaluates them through the Wasm engine where possible, falling back to
+// the interpreter for each policy which cannot be compiled
+func ScannerWithWasm(enabled bool) ScannerOption {
+	return func(s ConfigurableScanner) {
+		s.SetWasmEnabled(enabled)
+	}
+}